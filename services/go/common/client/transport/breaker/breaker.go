@@ -0,0 +1,340 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
+	"github.com/phuchnd/eeaao/services/go/common/observability/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Default settings of a Breaker, used for the zero fields of Settings.
+const (
+	DefaultMinRequests         = 10
+	DefaultWindowMs            = 60000
+	DefaultOpenMs              = 30000
+	DefaultHalfOpenMaxRequests = 1
+)
+
+// Metrics reported on state changes, labeled by destination, and by from/to states for transitions.
+const (
+	StateMetricName       = "circuit_breaker_state"
+	TransitionsMetricName = "circuit_breaker_transitions_total"
+)
+
+// windowBuckets is the number of buckets of the sliding window.
+const windowBuckets = 10
+
+// ErrOpen is the cause of the errors of calls rejected by an open circuit, they are also ErrUnavailable.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit.
+type State int
+
+const (
+	// StateClosed lets all calls through.
+	StateClosed State = iota
+	// StateHalfOpen lets a few trial calls through, deciding whether to close or reopen the circuit.
+	StateHalfOpen
+	// StateOpen rejects all calls.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings configures a Breaker.
+type Settings struct {
+	// FailureRateThreshold opens the circuit once the failure rate of the window reaches it, from 0 to 1.
+	// 0 disables the breaker.
+	FailureRateThreshold float64
+	// MinRequests is the minimum number of calls in the window before the failure rate is evaluated.
+	MinRequests int
+	// WindowMs is the duration of the sliding window the failure rate is computed on.
+	WindowMs int
+	// OpenMs is the duration the circuit stays open before letting trial calls through.
+	OpenMs int
+	// HalfOpenMaxRequests is the number of trial calls, all must succeed to close the circuit.
+	HalfOpenMaxRequests int
+}
+
+// FailureClassifier decides whether a call error counts as a failure of the destination.
+type FailureClassifier func(err error) bool
+
+// DefaultFailureClassifier counts unavailable, overloaded, timed out and internal errors of the destination.
+// Other errors, e.g. not found or invalid arguments, and canceled calls, gRPC Canceled included, count as
+// successes.
+func DefaultFailureClassifier(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled {
+		return false
+	}
+
+	switch commonerrs.FromError(err).Code {
+	case commonerrs.CodeUnavailable, commonerrs.CodeResourceExhausted, commonerrs.CodeTimeOut,
+		commonerrs.CodeInternal, commonerrs.CodeUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Opt is an option on a given Breaker.
+type Opt func(b *Breaker)
+
+// WithMetrics returns an option that allows reporting the state changes through given metrics.
+func WithMetrics(m metrics.Metrics) Opt {
+	return func(b *Breaker) {
+		b.metrics = m
+	}
+}
+
+// WithFailureClassifier returns an option that allows setting of the errors counted as failures.
+func WithFailureClassifier(classifier FailureClassifier) Opt {
+	return func(b *Breaker) {
+		b.classifier = classifier
+	}
+}
+
+// WithClock returns an option that allows setting of the time source, e.g. in tests.
+func WithClock(now func() time.Time) Opt {
+	return func(b *Breaker) {
+		b.now = now
+	}
+}
+
+type bucket struct {
+	epoch     int64
+	successes int
+	failures  int
+}
+
+// Breaker is the circuit breaker of a destination. A nil Breaker lets all calls through.
+type Breaker struct {
+	name       string
+	settings   Settings
+	classifier FailureClassifier
+	metrics    metrics.Metrics
+	now        func() time.Time
+
+	mu                sync.Mutex
+	state             State
+	generation        int
+	openedAt          time.Time
+	buckets           [windowBuckets]bucket
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// New returns the circuit breaker of given destination, nil if the settings disable it.
+func New(name string, settings Settings, opts ...Opt) *Breaker {
+	if settings.FailureRateThreshold <= 0 {
+		return nil
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = DefaultMinRequests
+	}
+	if settings.WindowMs <= 0 {
+		settings.WindowMs = DefaultWindowMs
+	}
+	if settings.OpenMs <= 0 {
+		settings.OpenMs = DefaultOpenMs
+	}
+	if settings.HalfOpenMaxRequests <= 0 {
+		settings.HalfOpenMaxRequests = DefaultHalfOpenMaxRequests
+	}
+
+	b := &Breaker{
+		name:       name,
+		settings:   settings,
+		classifier: DefaultFailureClassifier,
+		metrics:    metrics.NewNopMetrics(),
+		now:        time.Now,
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	b.metrics.SetGauge(StateMetricName, float64(StateClosed), metrics.Labels{"destination": name})
+
+	return b
+}
+
+// State returns the current state of the circuit.
+func (b *Breaker) State() State {
+	if b == nil {
+		return StateClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An open circuit past its timeout moves to half-open on the next call
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openDuration() {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Execute calls fn unless the circuit is open, and records its outcome.
+//
+// Rejected calls fail fast with an error which is both ErrOpen and ErrUnavailable.
+func (b *Breaker) Execute(ctx context.Context, fn func() error) error {
+	done, err := b.Allow(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	done(err)
+	return err
+}
+
+// Allow reports whether a call can go through, the returned function must then be called with its outcome.
+func (b *Breaker) Allow(ctx context.Context) (func(err error), error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+
+	b.mu.Lock()
+	from := b.state
+	b.refresh()
+
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = commonerrs.Wrap(ErrOpen, commonerrs.CodeUnavailable, "")
+	case StateHalfOpen:
+		if b.halfOpenInFlight >= b.settings.HalfOpenMaxRequests {
+			err = commonerrs.Wrap(ErrOpen, commonerrs.CodeUnavailable, "")
+		} else {
+			b.halfOpenInFlight++
+		}
+	}
+	to, generation := b.state, b.generation
+	b.mu.Unlock()
+
+	b.report(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(err error) {
+		b.record(ctx, generation, b.classifier(err))
+	}, nil
+}
+
+// refresh moves an open circuit past its timeout to half-open, it must be called with the lock held.
+func (b *Breaker) refresh() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openDuration() {
+		b.transition(StateHalfOpen)
+	}
+}
+
+// record records the outcome of a call allowed in given generation, outcomes of previous states are dropped.
+func (b *Breaker) record(ctx context.Context, generation int, failure bool) {
+	b.mu.Lock()
+	from := b.state
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		successes, failures := b.count(failure)
+		total := successes + failures
+		if total >= b.settings.MinRequests && float64(failures)/float64(total) >= b.settings.FailureRateThreshold {
+			b.transition(StateOpen)
+		}
+	case StateHalfOpen:
+		b.halfOpenInFlight--
+		if failure {
+			b.transition(StateOpen)
+		} else {
+			b.halfOpenSuccesses++
+			if b.halfOpenSuccesses >= b.settings.HalfOpenMaxRequests {
+				b.transition(StateClosed)
+			}
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.report(ctx, from, to)
+}
+
+// count records an outcome in the window and returns the window totals, it must be called with the lock held.
+func (b *Breaker) count(failure bool) (successes, failures int) {
+	bucketDuration := time.Duration(b.settings.WindowMs) * time.Millisecond / windowBuckets
+	epoch := b.now().UnixNano() / int64(bucketDuration)
+
+	current := &b.buckets[epoch%windowBuckets]
+	if current.epoch != epoch {
+		*current = bucket{epoch: epoch}
+	}
+	if failure {
+		current.failures++
+	} else {
+		current.successes++
+	}
+
+	for _, bk := range b.buckets {
+		if epoch-bk.epoch < windowBuckets {
+			successes += bk.successes
+			failures += bk.failures
+		}
+	}
+	return successes, failures
+}
+
+// transition changes the state and resets its counters, it must be called with the lock held.
+func (b *Breaker) transition(to State) {
+	b.state = to
+	b.generation++
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccesses = 0
+
+	switch to {
+	case StateOpen:
+		b.openedAt = b.now()
+	case StateClosed:
+		b.buckets = [windowBuckets]bucket{}
+	}
+}
+
+func (b *Breaker) openDuration() time.Duration {
+	return time.Duration(b.settings.OpenMs) * time.Millisecond
+}
+
+// report logs and records a state change, if any.
+func (b *Breaker) report(ctx context.Context, from, to State) {
+	if from == to {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Warnw("circuit breaker state changed", "destination", b.name, "from", from.String(), "to", to.String())
+
+	b.metrics.SetGauge(StateMetricName, float64(to), metrics.Labels{"destination": b.name})
+	b.metrics.IncCounter(TransitionsMetricName, metrics.Labels{
+		"destination": b.name,
+		"from":        from.String(),
+		"to":          to.String(),
+	})
+}
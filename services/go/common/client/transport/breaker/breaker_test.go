@@ -0,0 +1,278 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUnavailable = commonerrs.Wrap(errors.New("connection refused"), commonerrs.CodeUnavailable, "")
+
+type fakeClock struct {
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// call is a call recorded by a test, after advancing the clock.
+type call struct {
+	advance time.Duration
+	failure bool
+}
+
+func calls(n int, failure bool) []call {
+	out := make([]call, n)
+	for i := range out {
+		out[i].failure = failure
+	}
+	return out
+}
+
+func execute(t *testing.T, b *Breaker, failure bool) error {
+	t.Helper()
+
+	return b.Execute(context.Background(), func() error {
+		if failure {
+			return errUnavailable
+		}
+		return nil
+	})
+}
+
+func TestBreaker_ClosedState(t *testing.T) {
+	settings := Settings{FailureRateThreshold: 0.5, MinRequests: 4, WindowMs: 1000, OpenMs: 5000}
+
+	tests := []struct {
+		name  string
+		calls []call
+		want  State
+	}{
+		{
+			name:  "below min requests",
+			calls: calls(3, true),
+			want:  StateClosed,
+		},
+		{
+			name:  "failure rate reached",
+			calls: append(calls(2, false), calls(2, true)...),
+			want:  StateOpen,
+		},
+		{
+			name:  "failure rate below threshold",
+			calls: append(calls(3, false), calls(1, true)...),
+			want:  StateClosed,
+		},
+		{
+			name: "failures out of the window",
+			calls: append(calls(3, true), []call{
+				{advance: 1100 * time.Millisecond, failure: true},
+				{failure: false},
+			}...),
+			want: StateClosed,
+		},
+		{
+			name: "failures across buckets of the window",
+			calls: []call{
+				{failure: true},
+				{advance: 300 * time.Millisecond, failure: true},
+				{advance: 300 * time.Millisecond, failure: false},
+				{advance: 300 * time.Millisecond, failure: true},
+			},
+			want: StateOpen,
+		},
+		{
+			name: "bucket reused by a later epoch",
+			calls: append(calls(3, true), []call{
+				// Same bucket index as the first calls, one window later
+				{advance: 1000 * time.Millisecond, failure: false},
+				{failure: false},
+				{failure: false},
+				{failure: true},
+			}...),
+			want: StateClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			b := New("test", settings, WithClock(clock.now))
+
+			for _, c := range tt.calls {
+				clock.advance(c.advance)
+				_ = execute(t, b, c.failure)
+			}
+
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreaker_OpenState(t *testing.T) {
+	clock := newFakeClock()
+	b := New("test", Settings{FailureRateThreshold: 1, MinRequests: 1, OpenMs: 5000}, WithClock(clock.now))
+	_ = execute(t, b, true)
+
+	called := false
+	err := b.Execute(context.Background(), func() error {
+		called = true
+		return nil
+	})
+	if called {
+		t.Error("Execute() called fn on an open circuit")
+	}
+	if !errors.Is(err, ErrOpen) || commonerrs.FromError(err).Code != commonerrs.CodeUnavailable {
+		t.Errorf("Execute() error = %v, want ErrOpen with code %s", err, commonerrs.CodeUnavailable)
+	}
+
+	clock.advance(4999 * time.Millisecond)
+	if got := b.State(); got != StateOpen {
+		t.Errorf("State() before the open timeout = %s, want %s", got, StateOpen)
+	}
+	clock.advance(time.Millisecond)
+	if got := b.State(); got != StateHalfOpen {
+		t.Errorf("State() after the open timeout = %s, want %s", got, StateHalfOpen)
+	}
+}
+
+func TestBreaker_HalfOpenState(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []bool
+		want     State
+	}{
+		{
+			name:     "all trials succeed",
+			outcomes: []bool{false, false},
+			want:     StateClosed,
+		},
+		{
+			name:     "first trial fails",
+			outcomes: []bool{true, false},
+			want:     StateOpen,
+		},
+		{
+			name:     "last trial fails",
+			outcomes: []bool{false, true},
+			want:     StateOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			b := New("test", Settings{FailureRateThreshold: 1, MinRequests: 1, OpenMs: 5000, HalfOpenMaxRequests: 2},
+				WithClock(clock.now))
+			_ = execute(t, b, true)
+			clock.advance(5 * time.Second)
+
+			// The trial calls are limited while in flight
+			var dones []func(error)
+			for range tt.outcomes {
+				done, err := b.Allow(context.Background())
+				if err != nil {
+					t.Fatalf("Allow() trial error = %v", err)
+				}
+				dones = append(dones, done)
+			}
+			if _, err := b.Allow(context.Background()); !errors.Is(err, ErrOpen) {
+				t.Fatalf("Allow() beyond the trials error = %v, want ErrOpen", err)
+			}
+
+			for i, failure := range tt.outcomes {
+				if failure {
+					dones[i](errUnavailable)
+				} else {
+					dones[i](nil)
+				}
+			}
+
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreaker_DropsOutcomesOfPreviousStates(t *testing.T) {
+	clock := newFakeClock()
+	b := New("test", Settings{FailureRateThreshold: 1, MinRequests: 1, OpenMs: 5000}, WithClock(clock.now))
+
+	// A slow call allowed while closed completes once the circuit is half-open
+	slowDone, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	_ = execute(t, b, true)
+	clock.advance(5 * time.Second)
+
+	trialDone, err := b.Allow(context.Background())
+	if err != nil {
+		t.Fatalf("Allow() trial error = %v", err)
+	}
+	slowDone(errUnavailable)
+	if got := b.State(); got != StateHalfOpen {
+		t.Fatalf("State() after a stale failure = %s, want %s", got, StateHalfOpen)
+	}
+
+	trialDone(nil)
+	if got := b.State(); got != StateClosed {
+		t.Errorf("State() after the trial = %s, want %s", got, StateClosed)
+	}
+}
+
+func TestBreaker_Nil(t *testing.T) {
+	b := New("test", Settings{})
+	if b != nil {
+		t.Fatalf("New() with a zero threshold = %v, want nil", b)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := execute(t, b, true); !errors.Is(err, errUnavailable) {
+			t.Fatalf("Execute() error = %v, want the error of fn", err)
+		}
+	}
+	if got := b.State(); got != StateClosed {
+		t.Errorf("State() = %s, want %s", got, StateClosed)
+	}
+}
+
+func TestDefaultFailureClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "unavailable", err: errUnavailable, want: true},
+		{name: "timeout", err: context.DeadlineExceeded, want: true},
+		{name: "internal", err: commonerrs.Wrap(errors.New("boom"), commonerrs.CodeInternal, ""), want: true},
+		{name: "unknown", err: errors.New("boom"), want: true},
+		{name: "not found", err: commonerrs.Wrap(errors.New("missing"), commonerrs.CodeNotFound, ""), want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "grpc canceled", err: status.Error(codes.Canceled, "canceled"), want: false},
+		{name: "grpc unavailable", err: status.Error(codes.Unavailable, "down"), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultFailureClassifier(tt.err); got != tt.want {
+				t.Errorf("DefaultFailureClassifier(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
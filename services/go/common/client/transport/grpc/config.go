@@ -1,10 +1,38 @@
 package grpc
 
+import (
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/retry"
+)
+
 type Config struct {
 	ServiceName         string
 	ExternalServiceName string
 	Host                string
 	Port                int
-	MaxRetries          int
-	BackoffDelaysMs     int
+	// MaxRetries is the maximum number of attempts of a call, retries included.
+	MaxRetries int
+	// BackoffDelaysMs is the backoff before the first retry, it grows exponentially with jitter.
+	BackoffDelaysMs int
+	// MaxBackoffMs caps the backoff between attempts, retry.DefaultMaxBackoff if unset.
+	MaxBackoffMs int
+	// RetryDeadlineMs bounds the total duration of a call, retries included, if set.
+	RetryDeadlineMs int
+	// CircuitBreaker fails calls fast while the external service is failing, it is disabled unless a
+	// failure rate threshold is set.
+	CircuitBreaker breaker.Settings
+	// Limits bound the rate and the concurrency of the calls, e.g. to stay within the quota of a third-party API.
+	Limits limiter.Settings
+	// IdempotentMethods are the full names of the methods retried by default, e.g. `/quest.QuestService/GetQuest`.
+	// Other methods are only retried if the call allows it, see retry.AllowNonIdempotent.
+	IdempotentMethods []string
+	// PropagateBaggage sends the baggage to the external service, on top of the request ID and the W3C trace
+	// context. Enable it only for trusted, e.g. internal, services: the baggage carries the caller identity.
+	PropagateBaggage bool
+}
+
+// retryPolicy returns the retry policy of the config.
+func (c *Config) retryPolicy() *retry.Policy {
+	return retry.NewPolicyFromMs(c.MaxRetries, c.BackoffDelaysMs, c.MaxBackoffMs, c.RetryDeadlineMs)
 }
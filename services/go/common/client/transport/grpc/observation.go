@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/avast/retry-go"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
 	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
 	"github.com/phuchnd/eeaao/services/go/common/observability/metrics"
 	"github.com/phuchnd/eeaao/services/go/common/observability/tracing"
@@ -15,8 +17,16 @@ import (
 )
 
 func propagateAndObservationUnaryClientInterceptor(cfg *Config, metricsExporter metrics.Metrics) grpc.UnaryClientInterceptor {
+	retryPolicy := cfg.retryPolicy()
+	circuitBreaker := breaker.New(cfg.ExternalServiceName, cfg.CircuitBreaker, breaker.WithMetrics(metricsExporter))
+	callLimiter := limiter.New(cfg.ExternalServiceName, cfg.Limits)
+	idempotent := make(map[string]bool, len(cfg.IdempotentMethods))
+	for _, method := range cfg.IdempotentMethods {
+		idempotent[method] = true
+	}
+
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		newCtx := tracing.PropagateRequestIDToContext(ctx)
+		ctx, span := startSpan(ctx, cfg, method, method)
 		var err error
 		start := time.Now()
 		defer func() {
@@ -24,21 +34,49 @@ func propagateAndObservationUnaryClientInterceptor(cfg *Config, metricsExporter
 			if err != nil {
 				code = status.Code(err)
 			}
-			metricsExporter.SendExternalServiceMetric(newCtx, start, cfg.ServiceName, cfg.ExternalServiceName, method, "", code.String())
+			metricsExporter.SendExternalServiceMetric(ctx, start, cfg.ServiceName, cfg.ExternalServiceName, method, "", code.String())
+			endSpan(span, err)
 		}()
 
-		err = retry.Do(func() error {
-			err := invoker(newCtx, method, req, reply, cc, opts...)
+		err = retryPolicy.Do(ctx, idempotent[method], func(ctx context.Context, attempt int) error {
+			attemptCtx, attemptSpan := startSpan(ctx, cfg, method+" attempt", method)
+			attemptSpan.SetAttribute(tracing.AttributeAttempt, attempt)
+
+			// Propagate per attempt so the callee is parented to the attempt span
+			newCtx := tracing.PropagateRequestIDToContext(attemptCtx)
+			if cfg.PropagateBaggage {
+				newCtx = tracing.PropagateBaggageToContext(newCtx)
+			}
+			err := callLimiter.Execute(newCtx, func() error {
+				return circuitBreaker.Execute(newCtx, func() error {
+					return invoker(newCtx, method, req, reply, cc, opts...)
+				})
+			})
+			endSpan(attemptSpan, err)
 			if err != nil {
 				logger := logging.FromContext(newCtx)
-				logger.With("error", err).Warn(fmt.Sprintf("%s: inner attempt failed", method))
+				logger.With("error", err, "attempt", attempt).Warn(fmt.Sprintf("%s: inner attempt failed", method))
 			}
 			return err
-		},
-			retry.Attempts(uint(cfg.MaxRetries)),
-			retry.Delay(time.Duration(cfg.BackoffDelaysMs)*time.Millisecond),
-			retry.LastErrorOnly(true),
-		)
-		return err
+		})
+		if err != nil {
+			// The raw error is observed, callers get a typed one which keeps the gRPC status
+			return commonerrs.FromError(err)
+		}
+		return nil
 	}
 }
+
+func startSpan(ctx context.Context, cfg *Config, name, method string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.StartSpan(ctx, name)
+	span.SetAttribute(tracing.AttributeRPCSystem, "grpc")
+	span.SetAttribute(tracing.AttributeRPCMethod, method)
+	span.SetAttribute(tracing.AttributePeerService, cfg.ExternalServiceName)
+	return ctx, span
+}
+
+func endSpan(span *tracing.Span, err error) {
+	span.SetAttribute(tracing.AttributeRPCGRPCStatusCode, int(status.Code(err)))
+	span.RecordError(err)
+	span.End()
+}
@@ -0,0 +1,361 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+)
+
+// Auth methods of AuthConfig.
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodOAuth2 = "oauth2"
+	AuthMethodHMAC   = "hmac"
+)
+
+// Headers set by the HMAC authenticator.
+const (
+	HeaderSignature          = "X-Signature"
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+)
+
+// UnsignedPayload is the body hash signed by the HMAC authenticator for streamed bodies which cannot be read twice.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// AuthConfig configures the authentication of the calls to an external service.
+type AuthConfig struct {
+	// Methods are the auth methods applied in order, among api_key, oauth2 and hmac. Signing should come last.
+	Methods []string     `config:"methods"`
+	APIKey  APIKeyConfig `config:"api_key"`
+	OAuth2  OAuth2Config `config:"oauth2"`
+	HMAC    HMACConfig   `config:"hmac"`
+}
+
+// APIKeyConfig configures a static API key, sent as a header unless QueryParam is set.
+type APIKeyConfig struct {
+	// Key can be a secret reference resolved by the config provider, e.g. `secret://env/FITNESS_API_KEY`.
+	Key        string `config:"key"`
+	Header     string `config:"header" default:"X-API-Key"`
+	QueryParam string `config:"query_param"`
+}
+
+// OAuth2Config configures the OAuth2 client credentials grant.
+type OAuth2Config struct {
+	TokenURL string `config:"token_url"`
+	ClientID string `config:"client_id"`
+	// ClientSecret can be a secret reference resolved by the config provider.
+	ClientSecret string   `config:"client_secret"`
+	Scopes       []string `config:"scopes"`
+	// Params are additional parameters of the token requests, e.g. an audience.
+	Params map[string]string `config:"params"`
+	// ExpiryDeltaMs is how long before their expiry tokens are refreshed.
+	ExpiryDeltaMs int `config:"expiry_delta_ms" default:"30000" validate:"min=0"`
+}
+
+// HMACConfig configures the HMAC-SHA256 signing of requests, see NewHMACAuthenticator.
+type HMACConfig struct {
+	KeyID string `config:"key_id"`
+	// Secret can be a secret reference resolved by the config provider.
+	Secret string `config:"secret"`
+}
+
+// Authenticator adds credentials to outgoing requests, it is called before each attempt.
+//
+//go:generate mockery --name=Authenticator --case=snake --disable-version-string
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+}
+
+// RefreshableAuthenticator is implemented by authenticators whose credentials expire, e.g. OAuth2 tokens.
+// Requests rejected with 401 are sent again once with refreshed credentials.
+type RefreshableAuthenticator interface {
+	Authenticator
+
+	// Invalidate drops the credentials of a rejected request, it returns false if they cannot be refreshed.
+	Invalidate(req *http.Request) bool
+}
+
+// newAuthenticators returns the authenticators of the config, token requests are sent with given client.
+func newAuthenticators(cfg AuthConfig, client *http.Client) ([]Authenticator, error) {
+	authenticators := make([]Authenticator, 0, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		switch method {
+		case AuthMethodAPIKey:
+			authenticators = append(authenticators, NewAPIKeyAuthenticator(cfg.APIKey))
+		case AuthMethodOAuth2:
+			authenticators = append(authenticators, NewOAuth2Authenticator(cfg.OAuth2, client))
+		case AuthMethodHMAC:
+			authenticators = append(authenticators, NewHMACAuthenticator(cfg.HMAC))
+		default:
+			return nil, invalidConfig(fmt.Errorf("unsupported auth method %q", method))
+		}
+	}
+
+	return authenticators, nil
+}
+
+type apiKeyAuthenticator struct {
+	cfg APIKeyConfig
+}
+
+// NewAPIKeyAuthenticator returns an authenticator sending a static API key.
+func NewAPIKeyAuthenticator(cfg APIKeyConfig) Authenticator {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	return &apiKeyAuthenticator{cfg: cfg}
+}
+
+func (a *apiKeyAuthenticator) Authenticate(_ context.Context, req *http.Request) error {
+	if a.cfg.QueryParam == "" {
+		req.Header.Set(a.cfg.Header, a.cfg.Key)
+		return nil
+	}
+
+	query := req.URL.Query()
+	query.Set(a.cfg.QueryParam, a.cfg.Key)
+	req.URL.RawQuery = query.Encode()
+	return nil
+}
+
+// oauth2TokenRequestTimeout bounds the token requests, which outlive the calls waiting for them.
+const oauth2TokenRequestTimeout = 30 * time.Second
+
+// oauth2Token is a cached access token.
+type oauth2Token struct {
+	authorization string
+	expiry        time.Time
+}
+
+// oauth2TokenFetch is an in-flight token request, done is closed once token or err is set.
+type oauth2TokenFetch struct {
+	done  chan struct{}
+	token *oauth2Token
+	err   error
+}
+
+type oauth2Authenticator struct {
+	cfg    OAuth2Config
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token *oauth2Token
+	fetch *oauth2TokenFetch
+}
+
+// NewOAuth2Authenticator returns an authenticator sending access tokens of the client credentials grant,
+// requested with given client. Tokens are cached until shortly before their expiry.
+//
+// Concurrent calls share a single token request, each of them stops waiting for it once its context is done.
+func NewOAuth2Authenticator(cfg OAuth2Config, client *http.Client) RefreshableAuthenticator {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &oauth2Authenticator{
+		cfg:    cfg,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (a *oauth2Authenticator) Authenticate(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	if a.token != nil && a.now().Before(a.token.expiry) {
+		authorization := a.token.authorization
+		a.mu.Unlock()
+
+		req.Header.Set("Authorization", authorization)
+		return nil
+	}
+
+	// Concurrent calls wait for the same token request
+	fetch := a.fetch
+	if fetch == nil {
+		fetch = &oauth2TokenFetch{done: make(chan struct{})}
+		a.fetch = fetch
+		go a.fetchToken(context.WithoutCancel(ctx), fetch)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return commonerrs.FromError(fmt.Errorf("oauth2 token request: %w", ctx.Err()))
+	case <-fetch.done:
+	}
+	if fetch.err != nil {
+		return fetch.err
+	}
+
+	req.Header.Set("Authorization", fetch.token.authorization)
+	return nil
+}
+
+// fetchToken requests a token, caches it and wakes up the calls waiting for it. The request is not bound
+// to the context of the call which started it, since other calls may be waiting for it.
+func (a *oauth2Authenticator) fetchToken(ctx context.Context, fetch *oauth2TokenFetch) {
+	ctx, cancel := context.WithTimeout(ctx, oauth2TokenRequestTimeout)
+	defer cancel()
+
+	token, err := a.requestToken(ctx)
+
+	a.mu.Lock()
+	if err == nil {
+		a.token = token
+	}
+	a.fetch = nil
+	a.mu.Unlock()
+
+	fetch.token, fetch.err = token, err
+	close(fetch.done)
+}
+
+func (a *oauth2Authenticator) Invalidate(req *http.Request) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The token may have been refreshed by a concurrent call already
+	if a.token != nil && a.token.authorization == req.Header.Get("Authorization") {
+		a.token = nil
+	}
+	return true
+}
+
+// tokenResponse is the successful response of a token request, see RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (a *oauth2Authenticator) requestToken(ctx context.Context) (*oauth2Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.cfg.Scopes, " "))
+	}
+	for key, value := range a.cfg.Params {
+		form.Set(key, value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, commonerrs.Wrap(fmt.Errorf("oauth2 token request: %w", err), commonerrs.CodeInvalidArgument, "")
+	}
+	req.Header.Set("Content-Type", ContentTypeForm)
+	req.Header.Set("Accept", ContentTypeJSON)
+	req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+
+	start := a.now()
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, commonerrs.Wrap(fmt.Errorf("oauth2 token request: %w", err), commonerrs.CodeUnavailable, "")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	if err != nil {
+		return nil, commonerrs.Wrap(fmt.Errorf("oauth2 token request: %w", err), commonerrs.CodeUnavailable, "")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2 token request got unexpected error code %d: %w", resp.StatusCode,
+			commonerrs.FromHTTPResponse(resp.StatusCode, body))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return nil, commonerrs.Wrap(fmt.Errorf("oauth2 token request: invalid token response: %w", err), commonerrs.CodeInternal, "")
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	// Tokens without expiry are kept until rejected
+	expiry := time.Unix(1<<62, 0)
+	if token.ExpiresIn > 0 {
+		expiry = start.Add(time.Duration(token.ExpiresIn)*time.Second - msDuration(a.cfg.ExpiryDeltaMs))
+	}
+
+	return &oauth2Token{
+		authorization: tokenType + " " + token.AccessToken,
+		expiry:        expiry,
+	}, nil
+}
+
+type hmacAuthenticator struct {
+	cfg HMACConfig
+	now func() time.Time
+}
+
+// NewHMACAuthenticator returns an authenticator signing requests with HMAC-SHA256, e.g. for internal calls.
+//
+// The signature is the hex-encoded HMAC of the string to sign, see HMACStringToSign. It is sent along with
+// the key ID and the Unix timestamp in the HeaderSignature, HeaderSignatureKeyID and HeaderSignatureTimestamp
+// headers.
+func NewHMACAuthenticator(cfg HMACConfig) Authenticator {
+	return &hmacAuthenticator{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+func (a *hmacAuthenticator) Authenticate(_ context.Context, req *http.Request) error {
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return commonerrs.Wrap(fmt.Errorf("hmac signing: unable to read request body: %w", err), commonerrs.CodeInternal, "")
+	}
+
+	timestamp := strconv.FormatInt(a.now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(a.cfg.Secret))
+	mac.Write([]byte(HMACStringToSign(req.Method, req.URL, timestamp, bodyHash)))
+
+	req.Header.Set(HeaderSignatureKeyID, a.cfg.KeyID)
+	req.Header.Set(HeaderSignatureTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// HMACStringToSign returns the string signed by the HMAC authenticator: the method, the request URI, the
+// timestamp and the hex-encoded SHA-256 of the body, separated by newlines.
+func HMACStringToSign(method string, reqURL *url.URL, timestamp, bodyHash string) string {
+	return strings.Join([]string{method, reqURL.RequestURI(), timestamp, bodyHash}, "\n")
+}
+
+// hashBody returns the hex-encoded SHA-256 of the request body, read from a copy of the body.
+func hashBody(req *http.Request) (string, error) {
+	hash := sha256.New()
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody == nil:
+		return UnsignedPayload, nil
+	default:
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = body.Close()
+		}()
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+)
+
+// ContentTypeOctetStream is the default content type of streamed request bodies.
+const ContentTypeOctetStream = "application/octet-stream"
+
+// acceptEncoding is sent unless the request sets its own Accept-Encoding, responses are decompressed by
+// decompressBody.
+const acceptEncoding = "gzip, deflate"
+
+// maxErrorBodyBytes bounds the error response bodies read when no max response size is configured.
+const maxErrorBodyBytes = 1 << 20
+
+// ErrResponseTooLarge is the cause of the errors of responses exceeding Config.MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// StreamResponse is a response whose body is read by the caller, who must close it.
+type StreamResponse struct {
+	Response
+	Body io.ReadCloser
+}
+
+// setStreamBody sets a streamed request body. Seekers are rewound for retries, other readers can only
+// be sent once unless getBody reopens them.
+func setStreamBody(httpReq *http.Request, body io.Reader, getBody func() (io.ReadCloser, error)) error {
+	if getBody != nil {
+		first, err := getBody()
+		if err != nil {
+			return err
+		}
+		httpReq.Body, httpReq.GetBody = first, getBody
+		return nil
+	}
+
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok {
+		httpReq.Body = io.NopCloser(body)
+		return nil
+	}
+
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	// The caller owns the seeker, e.g. an open file, it must not be closed by the transport
+	httpReq.Body = io.NopCloser(seeker)
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(seeker), nil
+	}
+	return nil
+}
+
+// isRewindable reports whether the request body can be sent again.
+func isRewindable(httpReq *http.Request) bool {
+	return httpReq.Body == nil || httpReq.Body == http.NoBody || httpReq.GetBody != nil
+}
+
+// decompressBody decodes the gzip or deflate response bodies the transport did not decode itself. Empty
+// bodies, e.g. of HEAD requests, are left as is.
+func decompressBody(httpResp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(httpResp.Header.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+		return nil
+	}
+	if isEmptyBody(httpResp) {
+		return nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+	if encoding == "deflate" {
+		reader, err = zlib.NewReader(httpResp.Body)
+	} else {
+		reader, err = gzip.NewReader(httpResp.Body)
+	}
+	if err != nil {
+		return commonerrs.Wrap(fmt.Errorf("unable to decompress response body: %w", err), commonerrs.CodeInternal, "")
+	}
+
+	httpResp.Body = &decompressedBody{Reader: reader, decompressor: reader, body: httpResp.Body}
+	httpResp.Header.Del("Content-Encoding")
+	httpResp.Header.Del("Content-Length")
+	httpResp.ContentLength = -1
+	httpResp.Uncompressed = true
+	return nil
+}
+
+// isEmptyBody reports whether a response has no body, peeking at it if its length is unknown.
+func isEmptyBody(httpResp *http.Response) bool {
+	if httpResp.Request != nil && httpResp.Request.Method == http.MethodHead {
+		return true
+	}
+	if httpResp.StatusCode == http.StatusNoContent || httpResp.StatusCode == http.StatusNotModified ||
+		httpResp.ContentLength == 0 || httpResp.Body == nil || httpResp.Body == http.NoBody {
+		return true
+	}
+	if httpResp.ContentLength > 0 {
+		return false
+	}
+
+	peeked := bufio.NewReader(httpResp.Body)
+	if _, err := peeked.Peek(1); err == io.EOF {
+		return true
+	}
+	httpResp.Body = &peekedBody{Reader: peeked, Closer: httpResp.Body}
+	return false
+}
+
+type peekedBody struct {
+	*bufio.Reader
+	io.Closer
+}
+
+type decompressedBody struct {
+	io.Reader
+	decompressor io.Closer
+	body         io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	_ = b.decompressor.Close()
+	return b.body.Close()
+}
+
+// limitedBody fails reads past its limit with ErrResponseTooLarge, instead of silently truncating.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{body: body, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.tooLarge()
+	}
+	// Read one more byte than allowed to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), b.tooLarge()
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+func (b *limitedBody) tooLarge() error {
+	return commonerrs.Wrap(ErrResponseTooLarge, commonerrs.CodeInternal, "")
+}
+
+// cancelOnClose releases the context of a streamed response once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,398 @@
+// Package cassette records the interactions of an HTTP client to golden files and replays them offline,
+// e.g. for integration tests of external service clients:
+//
+//	rec, err := cassette.New("testdata/fitness_provider.json", cassette.WithMode(cassette.ModeFromEnv(cassette.ModeReplay)))
+//	...
+//	defer func() { _ = rec.Save() }()
+//	client, err := http.NewHTTPClient(cfg, metrics.NewNopMetrics(), http.WithRoundTripper(rec.Wrap))
+//
+// Run the tests with CASSETTE_MODE=record against the real service to refresh the golden files.
+package cassette
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
+)
+
+// ModeEnv is the env var read by ModeFromEnv.
+const ModeEnv = "CASSETTE_MODE"
+
+// ErrNoInteraction is the error of requests with no matching interaction in replay mode.
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches the request")
+
+// Mode selects whether requests are sent or replayed.
+type Mode string
+
+const (
+	// ModeReplay replays the recorded interactions, requests are never sent.
+	ModeReplay Mode = "replay"
+	// ModeRecord sends all requests and records them, replacing the recorded interactions.
+	ModeRecord Mode = "record"
+	// ModeReplayOrRecord replays the matching interactions, and sends and records the other requests.
+	ModeReplayOrRecord Mode = "replay_or_record"
+)
+
+// ModeFromEnv returns the mode set by the ModeEnv env var, or given default if unset or invalid.
+func ModeFromEnv(defaultMode Mode) Mode {
+	switch mode := Mode(os.Getenv(ModeEnv)); mode {
+	case ModeReplay, ModeRecord, ModeReplayOrRecord:
+		return mode
+	default:
+		return defaultMode
+	}
+}
+
+// RedactedValue replaces the redacted header values.
+const RedactedValue = logging.RedactedValue
+
+// DefaultRedactedHeaders are the headers whose values are never recorded.
+var DefaultRedactedHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Signature",
+}
+
+// defaultRedactorConfig scrubs the credentials from the recorded URLs and bodies.
+var defaultRedactorConfig = &logging.Config{
+	RedactFields:      []string{"password", "secret", "client_secret", "token", "access_token", "refresh_token", "id_token", "api_key"},
+	RedactQueryParams: []string{"token", "access_token", "refresh_token", "id_token", "code", "api_key", "key", "signature"},
+}
+
+// Cassette is the content of a golden file.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+
+	replayed bool
+}
+
+// Request is a recorded request, after redaction.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded response, after redaction. Compressed bodies are recorded decompressed.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded body, stored as a string if it is valid UTF-8 and as base64 otherwise.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Recorder records and replays the interactions of one golden file.
+type Recorder interface {
+	// Wrap returns a round tripper recording or replaying the requests sent to next, see
+	// http.WithRoundTripper.
+	Wrap(next http.RoundTripper) http.RoundTripper
+	// Save writes the recorded interactions to the golden file, unless in replay mode.
+	Save() error
+}
+
+type recorderImpl struct {
+	path            string
+	mode            Mode
+	matcher         Matcher
+	redactedHeaders map[string]bool
+	redactor        *logging.Redactor
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// Opt is an option on a given Recorder.
+type Opt func(r *recorderImpl)
+
+// WithMode returns an option that allows setting of the mode, ModeReplay by default.
+func WithMode(mode Mode) Opt {
+	return func(r *recorderImpl) {
+		r.mode = mode
+	}
+}
+
+// WithMatcher returns an option that allows setting of how requests are matched against the recorded
+// ones, DefaultMatcher by default.
+func WithMatcher(matcher Matcher) Opt {
+	return func(r *recorderImpl) {
+		r.matcher = matcher
+	}
+}
+
+// WithRedactedHeaders returns an option that allows redacting headers on top of DefaultRedactedHeaders.
+func WithRedactedHeaders(names ...string) Opt {
+	return func(r *recorderImpl) {
+		for _, name := range names {
+			r.redactedHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// WithRedactor returns an option that allows setting of the redactor of the URLs and bodies, replacing
+// the default one which scrubs credentials, emails and JWTs.
+func WithRedactor(redactor *logging.Redactor) Opt {
+	return func(r *recorderImpl) {
+		r.redactor = redactor
+	}
+}
+
+// New returns the recorder of the golden file at path. The file must exist in replay mode.
+func New(path string, opts ...Opt) (Recorder, error) {
+	redactor, err := logging.NewRedactor(defaultRedactorConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recorderImpl{
+		path:            path,
+		mode:            ModeReplay,
+		matcher:         DefaultMatcher(),
+		redactedHeaders: map[string]bool{},
+		redactor:        redactor,
+		cassette:        &Cassette{},
+	}
+	WithRedactedHeaders(DefaultRedactedHeaders...)(r)
+	for _, o := range opts {
+		o(r)
+	}
+
+	if r.mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && r.mode == ModeReplayOrRecord:
+		return r, nil
+	case err != nil:
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, r.cassette); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+func (r *recorderImpl) Wrap(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return r.roundTrip(next, req)
+	})
+}
+
+func (r *recorderImpl) Save() error {
+	if r.mode == ModeReplay {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("cassette %s: %w", r.path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("cassette %s: %w", r.path, err)
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+func (r *recorderImpl) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := r.redactRequest(req, body)
+
+	if r.mode != ModeRecord {
+		if interaction := r.find(recorded); interaction != nil {
+			return interaction.Response.toHTTP(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recorded.Method, recorded.URL)
+		}
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request: *recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    r.redactHeaders(resp.Header),
+			Body:       Body(r.redactor.RedactString(string(respBody))),
+		},
+		replayed: true,
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// find returns the first matching interaction not replayed yet, or the last matching one if all were
+// replayed, e.g. for polled endpoints.
+func (r *recorderImpl) find(req *Request) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if !r.matcher(req, &interaction.Request) {
+			continue
+		}
+		if !interaction.replayed {
+			interaction.replayed = true
+			return interaction
+		}
+		last = interaction
+	}
+	return last
+}
+
+func (r *recorderImpl) redactRequest(req *http.Request, body []byte) *Request {
+	return &Request{
+		Method:  req.Method,
+		URL:     r.redactor.RedactString(req.URL.String()),
+		Headers: r.redactHeaders(req.Header),
+		Body:    Body(r.redactor.RedactString(string(body))),
+	}
+}
+
+func (r *recorderImpl) redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name := range redacted {
+		if r.redactedHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = []string{RedactedValue}
+		}
+	}
+	return redacted
+}
+
+func (resp *Response) toHTTP(req *http.Request) *http.Response {
+	header := resp.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// readRequestBody reads the request body, leaving it to read again.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cassette: unable to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// readResponseBody reads and decompresses the response body, leaving it to read again.
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var reader io.Reader = resp.Body
+	var err error
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(resp.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(resp.Body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cassette: unable to decompress response body: %w", err)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: unable to read response body: %w", err)
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,132 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// Matcher reports whether a request matches a recorded one, both redacted.
+type Matcher func(req, recorded *Request) bool
+
+// DefaultMatcher matches the method, the URL and the body.
+func DefaultMatcher() Matcher {
+	return All(MatchMethod(), MatchURL(), MatchBody())
+}
+
+// All matches if all given matchers match.
+func All(matchers ...Matcher) Matcher {
+	return func(req, recorded *Request) bool {
+		for _, m := range matchers {
+			if !m(req, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches if any given matcher matches.
+func Any(matchers ...Matcher) Matcher {
+	return func(req, recorded *Request) bool {
+		for _, m := range matchers {
+			if m(req, recorded) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMethod matches the method.
+func MatchMethod() Matcher {
+	return func(req, recorded *Request) bool {
+		return req.Method == recorded.Method
+	}
+}
+
+// MatchURL matches the whole URL, the order of the query parameters included.
+func MatchURL() Matcher {
+	return func(req, recorded *Request) bool {
+		return req.URL == recorded.URL
+	}
+}
+
+// MatchPath matches the scheme, the host and the path of the URL.
+func MatchPath() Matcher {
+	return func(req, recorded *Request) bool {
+		reqURL, recordedURL, ok := parseURLs(req, recorded)
+		return ok && reqURL.Scheme == recordedURL.Scheme && reqURL.Host == recordedURL.Host && reqURL.Path == recordedURL.Path
+	}
+}
+
+// MatchQuery matches the query parameters in any order, except the ignored ones, e.g. timestamps.
+func MatchQuery(ignored ...string) Matcher {
+	return func(req, recorded *Request) bool {
+		reqURL, recordedURL, ok := parseURLs(req, recorded)
+		if !ok {
+			return false
+		}
+
+		reqQuery, recordedQuery := reqURL.Query(), recordedURL.Query()
+		for _, key := range ignored {
+			reqQuery.Del(key)
+			recordedQuery.Del(key)
+		}
+		return reflect.DeepEqual(reqQuery, recordedQuery)
+	}
+}
+
+// MatchHeaders matches the values of given headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(req, recorded *Request) bool {
+		for _, name := range names {
+			key := http.CanonicalHeaderKey(name)
+			if !reflect.DeepEqual(req.Headers[key], recorded.Headers[key]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchBody matches the bodies byte for byte.
+func MatchBody() Matcher {
+	return func(req, recorded *Request) bool {
+		return bytes.Equal(req.Body, recorded.Body)
+	}
+}
+
+// MatchJSONBody matches the JSON bodies regardless of their formatting and key order, except the ignored
+// top-level fields, e.g. request IDs. Bodies which are not JSON are matched byte for byte.
+func MatchJSONBody(ignored ...string) Matcher {
+	return func(req, recorded *Request) bool {
+		var reqBody, recordedBody interface{}
+		if json.Unmarshal(req.Body, &reqBody) != nil || json.Unmarshal(recorded.Body, &recordedBody) != nil {
+			return bytes.Equal(req.Body, recorded.Body)
+		}
+
+		for _, body := range []interface{}{reqBody, recordedBody} {
+			if fields, ok := body.(map[string]interface{}); ok {
+				for _, key := range ignored {
+					delete(fields, key)
+				}
+			}
+		}
+		return reflect.DeepEqual(reqBody, recordedBody)
+	}
+}
+
+func parseURLs(req, recorded *Request) (*url.URL, *url.URL, bool) {
+	reqURL, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, nil, false
+	}
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return nil, nil, false
+	}
+	return reqURL, recordedURL, true
+}
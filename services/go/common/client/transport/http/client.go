@@ -1,14 +1,18 @@
 package http
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/retry"
 	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
 	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
 	"github.com/phuchnd/eeaao/services/go/common/observability/metrics"
@@ -20,153 +24,407 @@ type Client interface {
 	Do(ctx context.Context, method, reqURL string, req []byte, resp interface{}, headers map[string]string) (int, error)
 	GET(ctx context.Context, reqURL string, resp interface{}, headers map[string]string) (int, error)
 	POST(ctx context.Context, reqURL string, req []byte, resp interface{}, headers map[string]string) (int, error)
+	// Execute sends a request and decodes the response body into resp, unless resp is nil.
+	// The response is returned along with errors whenever a status was received. See Call for typed requests.
+	Execute(ctx context.Context, req *Request, resp interface{}) (*Response, error)
+	// Stream sends a request and returns the response with its body left to read, e.g. for large exports.
+	// The caller must close the body.
+	Stream(ctx context.Context, req *Request) (*StreamResponse, error)
 }
 
 type httpClientImpl struct {
 	cfg             *Config
 	client          *http.Client
 	metricsExporter metrics.Metrics
+	retryPolicy     *retry.Policy
+	breaker         *breaker.Breaker
+	limiter         *limiter.Limiter
+	authenticators  []Authenticator
 }
 
-func NewHTTPClient(cfg *Config, metricsExporter metrics.Metrics) Client {
-	return &httpClientImpl{
+// ClientOpt is an option on a given client.
+type ClientOpt func(c *httpClientImpl)
+
+// WithAuthenticators returns an option that allows adding authenticators, applied in order after the ones
+// of the config, e.g. for credentials not expressible in the config.
+func WithAuthenticators(authenticators ...Authenticator) ClientOpt {
+	return func(c *httpClientImpl) {
+		c.authenticators = append(c.authenticators, authenticators...)
+	}
+}
+
+// WithRoundTripper returns an option that allows wrapping the transport of the client, e.g. to record
+// and replay calls in tests, see the cassette package. Token requests of the authenticators are wrapped too.
+func WithRoundTripper(wrap func(next http.RoundTripper) http.RoundTripper) ClientOpt {
+	return func(c *httpClientImpl) {
+		c.client.Transport = wrap(c.client.Transport)
+	}
+}
+
+// NewHTTPClient returns the client of an external service, it fails if the TLS, proxy or auth config is invalid.
+func NewHTTPClient(cfg *Config, metricsExporter metrics.Metrics, opts ...ClientOpt) (Client, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("http client %s: %w", cfg.ExternalServiceName, err)
+	}
+	client := &http.Client{Transport: transport}
+
+	authenticators, err := newAuthenticators(cfg.Auth, client)
+	if err != nil {
+		return nil, fmt.Errorf("http client %s: %w", cfg.ExternalServiceName, err)
+	}
+
+	c := &httpClientImpl{
 		cfg:             cfg,
-		client:          &http.Client{},
+		client:          client,
 		metricsExporter: metricsExporter,
+		retryPolicy:     cfg.retryPolicy(),
+		breaker:         breaker.New(cfg.ExternalServiceName, cfg.CircuitBreaker, breaker.WithMetrics(metricsExporter)),
+		limiter:         limiter.New(cfg.ExternalServiceName, cfg.Limits),
+		authenticators:  authenticators,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c, nil
+}
+
+func (t *httpClientImpl) Do(ctx context.Context, method string, reqURL string, req []byte, resp interface{}, headers map[string]string) (int, error) {
+	return t.executeRaw(ctx, method, reqURL, req, resp, headers)
+}
+
+func (t *httpClientImpl) GET(ctx context.Context, reqURL string, resp interface{}, headers map[string]string) (int, error) {
+	return t.executeRaw(ctx, http.MethodGet, reqURL, nil, resp, headers)
+}
+
+func (t *httpClientImpl) POST(ctx context.Context, reqURL string, req []byte, resp interface{}, headers map[string]string) (int, error) {
+	return t.executeRaw(ctx, http.MethodPost, reqURL, req, resp, headers)
+}
+
+// executeRaw sends a JSON request whose body is already encoded.
+func (t *httpClientImpl) executeRaw(ctx context.Context, method string, reqURL string, req []byte, resp interface{}, headers map[string]string) (int, error) {
+	request := &Request{
+		Method:  method,
+		URL:     reqURL,
+		Headers: headers,
 	}
+	if len(req) > 0 {
+		request.Body = req
+	}
+
+	httpResp, err := t.Execute(ctx, request, &resp)
+	return httpResp.StatusCode, err
 }
 
-func (t *httpClientImpl) Do(ctx context.Context, method string, reqURL string, req []byte, resp interface{}, headers map[string]string) (responseCode int, err error) {
-	requestBody := strings.NewReader(string(req))
-	var httpReq *http.Request
-	httpReq, err = http.NewRequest(method, reqURL, requestBody)
+func (t *httpClientImpl) Execute(ctx context.Context, req *Request, resp interface{}) (*Response, error) {
+	responseCodec := req.ResponseCodec
+	if responseCodec == nil {
+		responseCodec = JSONCodec()
+	}
+
+	httpResp, response, err := t.send(ctx, req, responseCodec, nil)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return response, err
 	}
 
-	if err := t.retryAndObserve(ctx, httpReq, func(ctx context.Context) (int, error) {
-		responseCode, err = t.sendRequest(ctx, httpReq, &resp, headers)
-		return responseCode, err
-	}); err != nil {
-		logger := logging.FromContext(ctx)
-		logger.Errorw(fmt.Sprintf("[%s] %s failed with status code %d", method, httpReq.URL.Path, responseCode), "err", err)
-		return responseCode, err
+	logger := logging.FromContext(ctx)
+	httpRespBody, _ := io.ReadAll(httpResp.Body)
+
+	// Not parsing body in-case request have no content
+	if resp != nil && httpResp.StatusCode != http.StatusNoContent && len(httpRespBody) != 0 {
+		if err = responseCodec.Decode(httpRespBody, resp); err != nil {
+			logger.Errorw(fmt.Sprintf("[%s] %s failed, unable to decode repose body", req.Method, httpResp.Request.URL.Path), "err", err, "request_url", httpResp.Request.URL, "response_code", httpResp.StatusCode)
+			return response, errors.Join(
+				fmt.Errorf("[%s] %s failed, unable to decode repose body", req.Method, httpResp.Request.URL.Path),
+				err,
+			)
+		}
 	}
-	return responseCode, err
+
+	logger.Infow(fmt.Sprintf("[%s] %s success", req.Method, httpResp.Request.URL.Path), "request_url", httpResp.Request.URL, "response_code", httpResp.StatusCode)
+	return response, nil
 }
 
-func (t *httpClientImpl) GET(ctx context.Context, reqURL string, resp interface{}, headers map[string]string) (responseCode int, err error) {
-	var httpReq *http.Request
-	httpReq, err = http.NewRequest(http.MethodGet, reqURL, nil)
-	if err != nil {
-		return http.StatusInternalServerError, err
+func (t *httpClientImpl) Stream(ctx context.Context, req *Request) (*StreamResponse, error) {
+	responseCodec := req.ResponseCodec
+	if responseCodec == nil {
+		responseCodec = JSONCodec()
 	}
-	if err := t.retryAndObserve(ctx, httpReq, func(ctx context.Context) (int, error) {
-		responseCode, err = t.sendRequest(ctx, httpReq, &resp, headers)
-		return responseCode, err
-	}); err != nil {
-		logger := logging.FromContext(ctx)
-		logger.Errorw(fmt.Sprintf("[GET] %s failed with status code %d", httpReq.URL.Path, responseCode), "err", err)
-		return responseCode, err
+
+	// The body outlives the retry loop, so the request is bound to the caller context rather than to the
+	// attempt one, and released once the body is closed
+	streamCtx, cancel := context.WithCancel(ctx)
+	httpResp, response, err := t.send(ctx, req, responseCodec, streamCtx)
+	if err != nil {
+		cancel()
+		return &StreamResponse{Response: *response}, err
 	}
-	return responseCode, nil
+
+	return &StreamResponse{
+		Response: *response,
+		Body:     &cancelOnClose{ReadCloser: httpResp.Body, cancel: cancel},
+	}, nil
 }
 
-func (t *httpClientImpl) POST(ctx context.Context, reqURL string, req []byte, resp interface{}, headers map[string]string) (responseCode int, err error) {
-	requestBody := strings.NewReader(string(req))
-	var httpReq *http.Request
-	httpReq, err = http.NewRequest(http.MethodPost, reqURL, requestBody)
+// send sends a request through the retry/observe path and returns the successful response. Streamed
+// requests are bound to streamCtx and their body is left to read, other requests are bound to the attempt
+// context and their body is read within the attempt.
+func (t *httpClientImpl) send(ctx context.Context, req *Request, responseCodec Codec, streamCtx context.Context) (*http.Response, *Response, error) {
+	httpReq, err := t.newHTTPRequest(req, responseCodec)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return nil, &Response{StatusCode: http.StatusInternalServerError}, err
 	}
-	if err := t.retryAndObserve(ctx, httpReq, func(ctx context.Context) (int, error) {
-		responseCode, err = t.sendRequest(ctx, httpReq, &resp, headers)
-		return responseCode, err
+
+	response := &Response{}
+	var httpResp *http.Response
+	if err := t.retryAndObserve(ctx, httpReq, func(attemptCtx context.Context, attempt int) (int, error) {
+		requestCtx, stopTimeout := t.attemptRequestContext(attemptCtx, streamCtx)
+		defer stopTimeout()
+
+		var err error
+		httpResp, err = t.sendRequest(attemptCtx, requestCtx, httpReq, attempt)
+		if err == nil && streamCtx == nil {
+			err = readBody(httpResp)
+		}
+		if httpResp != nil {
+			response.StatusCode, response.Header = httpResp.StatusCode, httpResp.Header
+		} else {
+			response.StatusCode, response.Header = http.StatusInternalServerError, nil
+		}
+		if err != nil && !isRewindable(httpReq) {
+			// The body was consumed by this attempt
+			err = retry.Permanent(err)
+		}
+		return response.StatusCode, err
 	}); err != nil {
 		logger := logging.FromContext(ctx)
-		logger.Errorw(fmt.Sprintf("[POST] %s failed with status code %d", httpReq.URL.Path, responseCode), "err", err)
-		return responseCode, err
+		logger.Errorw(fmt.Sprintf("[%s] %s failed with status code %d", req.Method, httpReq.URL.Path, response.StatusCode), "err", err)
+		return nil, response, err
 	}
-	return responseCode, nil
+	return httpResp, response, nil
 }
 
-func (t *httpClientImpl) sendRequest(ctx context.Context, req *http.Request, resp interface{}, headers map[string]string) (httpRespCode int, err error) {
-	// Append request_id to the out going header
-	tracing.PropagateRequestIDToHeader(ctx, &req.Header)
+// attemptRequestContext returns the context of the request of an attempt, bound to the request timeout.
+// The timeout of streamed requests stops once their response headers are received.
+func (t *httpClientImpl) attemptRequestContext(attemptCtx, streamCtx context.Context) (context.Context, func()) {
+	timeout := msDuration(t.cfg.RequestTimeoutMs)
+	if streamCtx == nil {
+		if timeout <= 0 {
+			return attemptCtx, func() {}
+		}
+		return context.WithTimeout(attemptCtx, timeout)
+	}
+
+	if timeout <= 0 {
+		return streamCtx, func() {}
+	}
+	ctx, cancel := context.WithCancel(streamCtx)
+	timer := time.AfterFunc(timeout, cancel)
+	return ctx, func() {
+		timer.Stop()
+	}
+}
+
+// newHTTPRequest builds the HTTP request of a request, encoding its body.
+func (t *httpClientImpl) newHTTPRequest(req *Request, responseCodec Codec) (*http.Request, error) {
+	httpReq, err := http.NewRequest(req.Method, t.resolveURL(req.URL), nil)
+	if err != nil {
+		return nil, commonerrs.Wrap(err, commonerrs.CodeInvalidArgument, "")
+	}
+
+	contentType, err := setRequestBody(httpReq, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ContentLength > 0 {
+		httpReq.ContentLength = req.ContentLength
+	}
+
+	if len(req.Query) > 0 {
+		query := httpReq.URL.Query()
+		for key, values := range req.Query {
+			query[key] = append(query[key], values...)
+		}
+		httpReq.URL.RawQuery = query.Encode()
+	}
 
 	// Set default header
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	httpReq.Header.Set("Accept", responseCodec.ContentType())
+	httpReq.Header.Set("Accept-Encoding", acceptEncoding)
 
 	// Set custom header
-	if len(headers) > 0 {
-		for key, val := range headers {
-			req.Header.Set(key, val)
-		}
+	for key, val := range req.Headers {
+		httpReq.Header.Set(key, val)
 	}
 
-	req = req.WithContext(ctx)
+	return httpReq, nil
+}
 
-	logger := logging.FromContext(ctx)
+// setRequestBody sets the body of the HTTP request of a request, encoding it if needed, and returns its
+// content type.
+func setRequestBody(httpReq *http.Request, req *Request) (string, error) {
+	codec := req.Codec
+	if codec == nil {
+		codec = JSONCodec()
+	}
 
-	httpResp, err := t.client.Do(req)
-	if err != nil {
-		logger.Errorw(fmt.Sprintf("[%s] %s failed", req.Method, req.URL.Path), "err", err)
-		return http.StatusInternalServerError, errors.Join(
-			fmt.Errorf("[%s] %s failed", req.Method, req.URL.Path),
-			err,
-		)
+	// Bodies opened by GetBody take precedence, whatever Body is
+	if req.GetBody != nil {
+		if err := setStreamBody(httpReq, nil, req.GetBody); err != nil {
+			return "", commonerrs.Wrap(fmt.Errorf("[%s] %s: unable to open request body: %w", req.Method, req.URL, err), commonerrs.CodeInvalidArgument, "")
+		}
+		return ContentTypeOctetStream, nil
+	}
+
+	switch body := req.Body.(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		setBytesBody(httpReq, body)
+		return codec.ContentType(), nil
+	case io.Reader:
+		if err := setStreamBody(httpReq, body, nil); err != nil {
+			return "", commonerrs.Wrap(fmt.Errorf("[%s] %s: unable to open request body: %w", req.Method, req.URL, err), commonerrs.CodeInvalidArgument, "")
+		}
+		return ContentTypeOctetStream, nil
+	default:
+		data, contentType, err := codec.Encode(body)
+		if err != nil {
+			return "", commonerrs.Wrap(fmt.Errorf("[%s] %s: unable to encode request body: %w", req.Method, req.URL, err), commonerrs.CodeInvalidArgument, "")
+		}
+		setBytesBody(httpReq, data)
+		return contentType, nil
 	}
+}
+
+// readBody buffers the body of a response, so that it can be read once the attempt is done.
+func readBody(httpResp *http.Response) error {
 	defer func() {
 		_ = httpResp.Body.Close()
 	}()
 
-	httpRespCode = httpResp.StatusCode
-	httpRespBody, _ := io.ReadAll(httpResp.Body)
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		if !errors.Is(err, ErrResponseTooLarge) {
+			err = commonerrs.Wrap(fmt.Errorf("[%s] %s failed, unable to read response body: %w", httpResp.Request.Method, httpResp.Request.URL.Path, err), commonerrs.CodeUnavailable, "")
+		}
+		return err
+	}
+	httpResp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
 
-	if httpRespCode < http.StatusOK || httpRespCode >= http.StatusBadRequest {
-		var errRes error
-		var errBody *errorResponse
+// roundTrip authenticates and sends a request, rewinding its body if it was sent before.
+func (t *httpClientImpl) roundTrip(ctx context.Context, req *http.Request, rewind bool) (*http.Response, error) {
+	for _, authenticator := range t.authenticators {
+		if err := authenticator.Authenticate(ctx, req); err != nil {
+			return nil, err
+		}
+	}
 
-		if err = json.Unmarshal(httpRespBody, &errBody); err != nil {
-			errRes = errors.Join(
-				fmt.Errorf("[%s] %s got unexpected error code %d", req.Method, req.URL.Path, httpRespCode),
-				errBody,
-			)
-		} else {
-			errRes = errors.Join(
-				commonerrs.ErrUnknown,
-				fmt.Errorf("[%s] %s got unexpected error code %d", req.Method, req.URL.Path, httpRespCode),
-			)
+	// Rewind the body consumed by a previous attempt, or read by the authenticators
+	if req.GetBody != nil && (rewind || len(t.authenticators) > 0) {
+		if req.Body != nil {
+			_ = req.Body.Close()
 		}
-		logger.Errorw(fmt.Sprintf("[%s] %s got unexpected error code", req.Method, req.URL.Path), "err", errRes, "request_url", httpResp.Request.URL, "response_code", httpRespCode, "httpRespBody", string(httpRespBody))
-		return httpRespCode, errRes
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
 	}
 
-	// Not parsing body in-case request have no content
-	if httpRespCode != http.StatusNoContent && len(httpRespBody) != 0 {
-		if err = json.Unmarshal(httpRespBody, &resp); err != nil {
-			logger.Errorw(fmt.Sprintf("[%s] %s failed, unable to decode repose body", req.Method, req.URL.Path), "err", err, "request_url", httpResp.Request.URL, "response_code", httpRespCode)
-			return httpRespCode, errors.Join(
-				fmt.Errorf("[%s] %s failed, unable to decode repose body", req.Method, req.URL.Path),
-				err,
-			)
+	httpResp, err := t.client.Do(req)
+	if err != nil {
+		logging.FromContext(ctx).Errorw(fmt.Sprintf("[%s] %s failed", req.Method, req.URL.Path), "err", err)
+		code := commonerrs.CodeUnavailable
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			code = commonerrs.CodeTimeOut
+		case errors.Is(err, context.Canceled):
+			code = commonerrs.CodeCanceled
+		}
+		return nil, commonerrs.Wrap(fmt.Errorf("[%s] %s failed: %w", req.Method, req.URL.Path, err), code, "")
+	}
+
+	return httpResp, nil
+}
+
+// invalidateCredentials drops the rejected credentials of a request, it reports whether they can be refreshed.
+func (t *httpClientImpl) invalidateCredentials(req *http.Request) bool {
+	refreshed := false
+	for _, authenticator := range t.authenticators {
+		if refreshable, ok := authenticator.(RefreshableAuthenticator); ok && refreshable.Invalidate(req) {
+			refreshed = true
 		}
 	}
+	return refreshed
+}
 
-	logger.Infow(fmt.Sprintf("[%s] %s success", req.Method, req.URL.Path), "request_url", httpResp.Request.URL, "response_code", httpRespCode)
-	return httpRespCode, nil
+// resolveURL prepends the base URL to the URLs without scheme.
+func (t *httpClientImpl) resolveURL(reqURL string) string {
+	if t.cfg.BaseURL == "" || strings.Contains(reqURL, "://") {
+		return reqURL
+	}
+	return strings.TrimSuffix(t.cfg.BaseURL, "/") + "/" + strings.TrimPrefix(reqURL, "/")
 }
 
-// errorResponse is a general error struct to parsing to get more error on the http response body
-type errorResponse struct {
-	Message string `json:"message"`
-	Err     string `json:"error"`
+func setBytesBody(httpReq *http.Request, data []byte) {
+	httpReq.Body = io.NopCloser(bytes.NewReader(data))
+	httpReq.ContentLength = int64(len(data))
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
 }
 
-func (e *errorResponse) Error() string {
-	if e == nil {
-		return ""
+// sendRequest sends one attempt of a request, bound to reqCtx. Error responses are read and closed, the
+// body of successful ones is left to the caller.
+func (t *httpClientImpl) sendRequest(ctx context.Context, reqCtx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	// Append request_id to the out going header
+	tracing.PropagateRequestIDToHeader(ctx, &req.Header)
+	if t.cfg.PropagateBaggage {
+		tracing.PropagateBaggageToHeader(ctx, &req.Header)
+	}
+
+	req = req.WithContext(reqCtx)
+
+	logger := logging.FromContext(ctx)
+
+	httpResp, err := t.roundTrip(ctx, req, attempt > 1)
+	if err == nil && httpResp.StatusCode == http.StatusUnauthorized && isRewindable(req) && t.invalidateCredentials(req) {
+		// The credentials were rejected, e.g. a revoked token, send again once with refreshed ones
+		_ = httpResp.Body.Close()
+		httpResp, err = t.roundTrip(ctx, req, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decompressBody(httpResp); err != nil {
+		_ = httpResp.Body.Close()
+		return httpResp, err
+	}
+	httpResp.Body = newLimitedBody(httpResp.Body, t.cfg.MaxResponseBytes)
+
+	httpRespCode := httpResp.StatusCode
+	if httpRespCode < http.StatusOK || httpRespCode >= http.StatusBadRequest {
+		defer func() {
+			_ = httpResp.Body.Close()
+		}()
+		httpRespBody, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorBodyBytes))
+
+		errRes := fmt.Errorf("[%s] %s got unexpected error code %d: %w", req.Method, req.URL.Path, httpRespCode,
+			commonerrs.FromHTTPResponse(httpRespCode, httpRespBody))
+		if retryAfter, ok := retry.ParseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()); ok {
+			errRes = retry.WithRetryAfter(errRes, retryAfter)
+		}
+		logger.Errorw(fmt.Sprintf("[%s] %s got unexpected error code", req.Method, req.URL.Path), "err", errRes, "request_url", httpResp.Request.URL, "response_code", httpRespCode, "httpRespBody", string(httpRespBody))
+		return httpResp, errRes
 	}
-	return fmt.Sprintf("%s| %s", e.Err, e.Message)
+
+	return httpResp, nil
 }
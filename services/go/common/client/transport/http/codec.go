@@ -0,0 +1,194 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types of the built-in codecs.
+const (
+	ContentTypeJSON      = "application/json"
+	ContentTypeProtobuf  = "application/x-protobuf"
+	ContentTypeForm      = "application/x-www-form-urlencoded"
+	ContentTypeMultipart = "multipart/form-data"
+)
+
+// Codec encodes request bodies and decodes response bodies.
+type Codec interface {
+	// ContentType is the media type of the codec, it is sent as the Accept header.
+	ContentType() string
+	// Encode encodes a request body and returns its Content-Type, which may carry parameters.
+	Encode(v interface{}) ([]byte, string, error)
+	// Decode decodes a response body into v.
+	Decode(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+// JSONCodec returns the codec of JSON bodies.
+func JSONCodec() Codec {
+	return jsonCodec{}
+}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, string, error) {
+	data, err := json.Marshal(v)
+	return data, ContentTypeJSON, err
+}
+
+func (jsonCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+// ProtobufCodec returns the codec of protobuf bodies, values must be proto.Message.
+func ProtobufCodec() Codec {
+	return protobufCodec{}
+}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Encode(v interface{}) ([]byte, string, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: protobuf codec cannot encode %T", commonerrs.ErrUnsupported, v)
+	}
+	data, err := proto.Marshal(msg)
+	return data, ContentTypeProtobuf, err
+}
+
+func (protobufCodec) Decode(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: protobuf codec cannot decode into %T", commonerrs.ErrUnsupported, v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+type formCodec struct{}
+
+// FormCodec returns the codec of URL-encoded form bodies, values are url.Values, map[string]string or
+// map[string][]string. Responses are decoded into *url.Values.
+func FormCodec() Codec {
+	return formCodec{}
+}
+
+func (formCodec) ContentType() string {
+	return ContentTypeForm
+}
+
+func (formCodec) Encode(v interface{}) ([]byte, string, error) {
+	var values url.Values
+	switch form := v.(type) {
+	case url.Values:
+		values = form
+	case map[string][]string:
+		values = form
+	case map[string]string:
+		values = url.Values{}
+		for key, value := range form {
+			values.Set(key, value)
+		}
+	default:
+		return nil, "", fmt.Errorf("%w: form codec cannot encode %T", commonerrs.ErrUnsupported, v)
+	}
+	return []byte(values.Encode()), ContentTypeForm, nil
+}
+
+func (formCodec) Decode(data []byte, v interface{}) error {
+	values, ok := v.(*url.Values)
+	if !ok {
+		return fmt.Errorf("%w: form codec cannot decode into %T", commonerrs.ErrUnsupported, v)
+	}
+	parsed, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	*values = parsed
+	return nil
+}
+
+// MultipartFile is a file part of a multipart form, e.g. a device export file.
+type MultipartFile struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// MultipartForm is the request body of the multipart codec.
+type MultipartForm struct {
+	Fields map[string]string
+	Files  []MultipartFile
+}
+
+type multipartCodec struct{}
+
+// MultipartCodec returns the codec of multipart form bodies, values are MultipartForm or *MultipartForm.
+// It cannot decode responses.
+func MultipartCodec() Codec {
+	return multipartCodec{}
+}
+
+func (multipartCodec) ContentType() string {
+	return ContentTypeMultipart
+}
+
+func (multipartCodec) Encode(v interface{}) ([]byte, string, error) {
+	var form *MultipartForm
+	switch f := v.(type) {
+	case MultipartForm:
+		form = &f
+	case *MultipartForm:
+		form = f
+	default:
+		return nil, "", fmt.Errorf("%w: multipart codec cannot encode %T", commonerrs.ErrUnsupported, v)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, value := range form.Fields {
+		if err := w.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, file := range form.Files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, file.FieldName, file.FileName))
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(file.Content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body.Bytes(), w.FormDataContentType(), nil
+}
+
+func (multipartCodec) Decode(_ []byte, v interface{}) error {
+	return fmt.Errorf("%w: multipart codec cannot decode into %T", commonerrs.ErrUnsupported, v)
+}
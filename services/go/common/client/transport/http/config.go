@@ -1,8 +1,107 @@
 package http
 
+import (
+	"time"
+
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/retry"
+	"github.com/phuchnd/eeaao/services/go/common/config"
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+)
+
+// Config configures a client of an external service. It can be loaded from YAML by registering it, see
+// RegisterConfig.
 type Config struct {
-	ServiceName         string
-	ExternalServiceName string
-	MaxRetries          int
-	BackoffDelaysMs     int
+	ServiceName         string `config:"service_name"`
+	ExternalServiceName string `config:"external_service_name"`
+	// BaseURL is prepended to the request URLs without scheme, e.g. `https://api.example.com/v1`.
+	BaseURL string `config:"base_url"`
+	// MaxRetries is the maximum number of attempts of a call, retries included.
+	MaxRetries int `config:"max_retries" default:"3" validate:"min=0"`
+	// BackoffDelaysMs is the backoff before the first retry, it grows exponentially with jitter.
+	BackoffDelaysMs int `config:"backoff_delays_ms" default:"100" validate:"min=0"`
+	// MaxBackoffMs caps the backoff between attempts, retry.DefaultMaxBackoff if unset.
+	MaxBackoffMs int `config:"max_backoff_ms" validate:"min=0"`
+	// RetryDeadlineMs is the overall timeout of a call, retries included, if set.
+	RetryDeadlineMs int `config:"retry_deadline_ms" validate:"min=0"`
+	// RequestTimeoutMs is the timeout of each attempt, if set. It bounds the whole response of buffered
+	// calls, and only the response headers of streamed ones.
+	RequestTimeoutMs int `config:"request_timeout_ms" default:"30000" validate:"min=0"`
+	// CircuitBreaker fails calls fast while the external service is failing, it is disabled unless a
+	// failure rate threshold is set.
+	CircuitBreaker breaker.Settings `config:"circuit_breaker"`
+	// MaxResponseBytes bounds the size of the decompressed response bodies, 0 does not bound them.
+	MaxResponseBytes int64 `config:"max_response_bytes" validate:"min=0"`
+	// Limits bound the rate and the concurrency of the calls, e.g. to stay within the quota of a third-party API.
+	Limits limiter.Settings `config:"limits"`
+	// Transport tunes the connections to the external service.
+	Transport TransportConfig `config:"transport"`
+	// TLS configures custom CAs and client certificates, the system CAs are used otherwise.
+	TLS TLSConfig `config:"tls"`
+	// Auth configures the credentials added to the requests, e.g. OAuth2 tokens.
+	Auth AuthConfig `config:"auth"`
+	// PropagateBaggage sends the baggage to the external service, on top of the request ID and the W3C trace
+	// context. Enable it only for trusted, e.g. internal, services: the baggage carries the caller identity.
+	PropagateBaggage bool `config:"propagate_baggage"`
+}
+
+// TransportConfig configures the connection pool, the connection timeouts and the proxy. Unset values
+// fall back to the ones of http.DefaultTransport.
+type TransportConfig struct {
+	DialTimeoutMs         int `config:"dial_timeout_ms" default:"30000" validate:"min=0"`
+	KeepAliveMs           int `config:"keep_alive_ms" default:"30000" validate:"min=0"`
+	TLSHandshakeTimeoutMs int `config:"tls_handshake_timeout_ms" default:"10000" validate:"min=0"`
+	// ResponseHeaderTimeoutMs bounds the wait for the response headers once the request is written, if set.
+	ResponseHeaderTimeoutMs int `config:"response_header_timeout_ms" validate:"min=0"`
+	// MaxIdleConns is the maximum number of idle connections, MaxIdleConnsPerHost the one per host,
+	// http.DefaultMaxIdleConnsPerHost if unset.
+	MaxIdleConns        int `config:"max_idle_conns" default:"100" validate:"min=0"`
+	MaxIdleConnsPerHost int `config:"max_idle_conns_per_host" validate:"min=0"`
+	// MaxConnsPerHost bounds the connections per host, dialing included, if set.
+	MaxConnsPerHost   int `config:"max_conns_per_host" validate:"min=0"`
+	IdleConnTimeoutMs int `config:"idle_conn_timeout_ms" default:"90000" validate:"min=0"`
+	// ProxyURL is the proxy of all requests, e.g. `http://proxy.internal:3128`. The HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY env vars are used if unset.
+	ProxyURL string `config:"proxy_url"`
+	// NoProxy are the hosts not sent through ProxyURL, in the format of NO_PROXY, e.g. `.internal,10.0.0.0/8`.
+	NoProxy []string `config:"no_proxy"`
+	// DisableProxy ignores the proxy env vars.
+	DisableProxy bool `config:"disable_proxy"`
+}
+
+// TLSConfig configures the TLS connections. PEM values can be secret references resolved by the config
+// provider, e.g. `secret://file//run/secrets/client.key`.
+type TLSConfig struct {
+	// CA is the PEM bundle of the CAs trusted on top of the system ones.
+	CA string `config:"ca"`
+	// ClientCert and ClientKey are the PEM certificate and key sent for mutual TLS.
+	ClientCert string `config:"client_cert"`
+	ClientKey  string `config:"client_key"`
+	// ServerName overrides the name the server certificate is verified against.
+	ServerName string `config:"server_name"`
+	// MinVersion is the minimum TLS version.
+	MinVersion string `config:"min_version" default:"1.2" validate:"oneof=1.2 1.3"`
+	// InsecureSkipVerify disables the verification of the server certificate, e.g. for local stubs.
+	InsecureSkipVerify bool `config:"insecure_skip_verify"`
+}
+
+// RegisterConfig registers the config of a client with given name, e.g. `payment_client`, in the config
+// registry. It must be called before the config provider is initialized, e.g. in an init function.
+func RegisterConfig(name string) {
+	registry.RegisterConfig(name, registry.NewStructConfig[Config](name))
+}
+
+// GetConfig returns the config of a client registered with given name.
+func GetConfig(cp config.Provider, name string) *Config {
+	return cp.Get(name).(*Config)
+}
+
+func msDuration(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// retryPolicy returns the retry policy of the config.
+func (c *Config) retryPolicy() *retry.Policy {
+	return retry.NewPolicyFromMs(c.MaxRetries, c.BackoffDelaysMs, c.MaxBackoffMs, c.RetryDeadlineMs)
 }
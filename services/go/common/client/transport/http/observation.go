@@ -6,33 +6,58 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/avast/retry-go"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/retry"
 	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
+	"github.com/phuchnd/eeaao/services/go/common/observability/tracing"
 )
 
 // doFunc is an executable function which will return http status code and the error
-type doFunc func(ctx context.Context) (int, error)
+type doFunc func(ctx context.Context, attempt int) (int, error)
 
 func (t *httpClientImpl) retryAndObserve(ctx context.Context, httpReq *http.Request, doFunc doFunc) error {
+	ctx, span := t.startSpan(ctx, httpReq, fmt.Sprintf("HTTP %s %s", httpReq.Method, httpReq.URL.Path))
 	start := time.Now()
 	var responseCode int
+	var err error
 	defer func() {
 		code := http.StatusText(responseCode)
 		t.metricsExporter.SendExternalServiceMetric(ctx, start, t.cfg.ServiceName, t.cfg.ExternalServiceName, httpReq.URL.Path, httpReq.Method, code)
+		endSpan(span, responseCode, err)
 	}()
 
-	err := retry.Do(func() error {
-		var err error
-		responseCode, err = doFunc(ctx)
+	err = t.retryPolicy.Do(ctx, retry.IsIdempotentHTTPMethod(httpReq.Method), func(ctx context.Context, attempt int) error {
+		attemptCtx, attemptSpan := t.startSpan(ctx, httpReq, fmt.Sprintf("HTTP %s %s attempt", httpReq.Method, httpReq.URL.Path))
+		attemptSpan.SetAttribute(tracing.AttributeAttempt, attempt)
+
+		err := t.limiter.Execute(attemptCtx, func() error {
+			return t.breaker.Execute(attemptCtx, func() error {
+				var err error
+				responseCode, err = doFunc(attemptCtx, attempt)
+				return err
+			})
+		})
+		endSpan(attemptSpan, responseCode, err)
 		if err != nil {
 			logger := logging.FromContext(ctx)
-			logger.Warnw(fmt.Sprintf("[%s] %s: inner attempt failed", httpReq.Method, httpReq.URL.Path), "error", err)
+			logger.Warnw(fmt.Sprintf("[%s] %s: inner attempt failed", httpReq.Method, httpReq.URL.Path), "error", err, "attempt", attempt)
 		}
 		return err
-	},
-		retry.Attempts(uint(t.cfg.MaxRetries)),
-		retry.Delay(time.Duration(t.cfg.BackoffDelaysMs)*time.Millisecond),
-		retry.LastErrorOnly(true),
-	)
+	})
 	return err
 }
+
+func (t *httpClientImpl) startSpan(ctx context.Context, httpReq *http.Request, name string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.StartSpan(ctx, name)
+	span.SetAttribute(tracing.AttributeHTTPMethod, httpReq.Method)
+	span.SetAttribute(tracing.AttributeHTTPPath, httpReq.URL.Path)
+	span.SetAttribute(tracing.AttributePeerService, t.cfg.ExternalServiceName)
+	return ctx, span
+}
+
+func endSpan(span *tracing.Span, responseCode int, err error) {
+	if responseCode != 0 {
+		span.SetAttribute(tracing.AttributeHTTPStatusCode, responseCode)
+	}
+	span.RecordError(err)
+	span.End()
+}
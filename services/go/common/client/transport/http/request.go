@@ -0,0 +1,205 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// Request is a request sent by Client.Execute.
+type Request struct {
+	Method  string
+	URL     string
+	Query   url.Values
+	Headers map[string]string
+	// Body is encoded by the codec, nil sends no body. A []byte body is sent as is, and an io.Reader is
+	// streamed. Streamed bodies are only sent again on retries if they are io.Seeker or GetBody is set.
+	Body interface{}
+	// GetBody opens the streamed body, once per attempt, e.g. by opening a file. It takes precedence over Body,
+	// which can be left nil.
+	GetBody func() (io.ReadCloser, error)
+	// ContentLength is the length of a streamed body if known, it is sent chunked otherwise.
+	ContentLength int64
+	// Codec encodes the body, JSONCodec by default.
+	Codec Codec
+	// ResponseCodec decodes the response body, JSONCodec by default.
+	ResponseCodec Codec
+}
+
+// Response is the status and the header of a response, its body is decoded separately.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// RequestOpt is an option on a given Request.
+type RequestOpt func(r *Request)
+
+// WithQuery returns an option that allows adding query parameters, e.g. built by a Query.
+func WithQuery(query url.Values) RequestOpt {
+	return func(r *Request) {
+		if r.Query == nil {
+			r.Query = url.Values{}
+		}
+		for key, values := range query {
+			r.Query[key] = append(r.Query[key], values...)
+		}
+	}
+}
+
+// WithHeader returns an option that allows setting a request header.
+func WithHeader(key, value string) RequestOpt {
+	return func(r *Request) {
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		r.Headers[key] = value
+	}
+}
+
+// WithHeaders returns an option that allows setting request headers.
+func WithHeaders(headers map[string]string) RequestOpt {
+	return func(r *Request) {
+		for key, value := range headers {
+			WithHeader(key, value)(r)
+		}
+	}
+}
+
+// WithCodec returns an option that allows setting of the request body codec. Call uses it for the response
+// too, unless WithResponseCodec is given or the codec cannot decode the response, e.g. the multipart codec
+// or the form codec into a type other than url.Values, in which case responses are decoded as JSON.
+func WithCodec(codec Codec) RequestOpt {
+	return func(r *Request) {
+		r.Codec = codec
+	}
+}
+
+// WithResponseCodec returns an option that allows setting of the response body codec.
+func WithResponseCodec(codec Codec) RequestOpt {
+	return func(r *Request) {
+		r.ResponseCodec = codec
+	}
+}
+
+// NoBody is the request type of calls sending no body.
+type NoBody struct{}
+
+// Call sends a request with a typed body and decodes the response into a Resp.
+func Call[Req, Resp any](ctx context.Context, client Client, method, reqURL string, body Req, opts ...RequestOpt) (Resp, *Response, error) {
+	req := &Request{
+		Method: method,
+		URL:    reqURL,
+	}
+	if _, noBody := any(body).(NoBody); !noBody {
+		req.Body = body
+	}
+	for _, o := range opts {
+		o(req)
+	}
+
+	var resp Resp
+	var target interface{} = &resp
+	// Pointer responses, e.g. proto messages, are allocated and decoded into directly
+	if v := reflect.ValueOf(&resp).Elem(); v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		target = resp
+	}
+
+	if req.ResponseCodec == nil && req.Codec != nil && canDecode(req.Codec, target) {
+		req.ResponseCodec = req.Codec
+	}
+
+	httpResp, err := client.Execute(ctx, req, target)
+	return resp, httpResp, err
+}
+
+// canDecode reports whether a request codec can decode a response into target.
+func canDecode(codec Codec, target interface{}) bool {
+	switch codec.(type) {
+	case multipartCodec:
+		return false
+	case formCodec:
+		_, ok := target.(*url.Values)
+		return ok
+	default:
+		return true
+	}
+}
+
+// Get sends a GET request, see Call.
+func Get[Resp any](ctx context.Context, client Client, reqURL string, opts ...RequestOpt) (Resp, *Response, error) {
+	return Call[NoBody, Resp](ctx, client, http.MethodGet, reqURL, NoBody{}, opts...)
+}
+
+// Post sends a POST request, see Call.
+func Post[Req, Resp any](ctx context.Context, client Client, reqURL string, body Req, opts ...RequestOpt) (Resp, *Response, error) {
+	return Call[Req, Resp](ctx, client, http.MethodPost, reqURL, body, opts...)
+}
+
+// Put sends a PUT request, see Call.
+func Put[Req, Resp any](ctx context.Context, client Client, reqURL string, body Req, opts ...RequestOpt) (Resp, *Response, error) {
+	return Call[Req, Resp](ctx, client, http.MethodPut, reqURL, body, opts...)
+}
+
+// Patch sends a PATCH request, see Call.
+func Patch[Req, Resp any](ctx context.Context, client Client, reqURL string, body Req, opts ...RequestOpt) (Resp, *Response, error) {
+	return Call[Req, Resp](ctx, client, http.MethodPatch, reqURL, body, opts...)
+}
+
+// Delete sends a DELETE request, see Call.
+func Delete[Resp any](ctx context.Context, client Client, reqURL string, opts ...RequestOpt) (Resp, *Response, error) {
+	return Call[NoBody, Resp](ctx, client, http.MethodDelete, reqURL, NoBody{}, opts...)
+}
+
+// Query builds query parameters.
+type Query struct {
+	values url.Values
+}
+
+// NewQuery returns an empty Query.
+func NewQuery() *Query {
+	return &Query{values: url.Values{}}
+}
+
+// Set sets a parameter, replacing its values. Times are formatted as RFC 3339, other values with fmt.
+func (q *Query) Set(key string, value interface{}) *Query {
+	q.values.Set(key, formatQueryValue(value))
+	return q
+}
+
+// Add adds a value to a parameter.
+func (q *Query) Add(key string, value interface{}) *Query {
+	q.values.Add(key, formatQueryValue(value))
+	return q
+}
+
+// SetIf sets a parameter only if cond is true, e.g. for optional filters.
+func (q *Query) SetIf(cond bool, key string, value interface{}) *Query {
+	if cond {
+		q.Set(key, value)
+	}
+	return q
+}
+
+// Values returns the built parameters.
+func (q *Query) Values() url.Values {
+	return q.values
+}
+
+func formatQueryValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
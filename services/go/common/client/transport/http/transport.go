@@ -0,0 +1,134 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newTransport returns the transport of the config, based on http.DefaultTransport.
+func newTransport(cfg *Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tc := cfg.Transport
+
+	// Same dialer as http.DefaultTransport, unless overridden
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if tc.DialTimeoutMs > 0 {
+		dialer.Timeout = msDuration(tc.DialTimeoutMs)
+	}
+	if tc.KeepAliveMs > 0 {
+		dialer.KeepAlive = msDuration(tc.KeepAliveMs)
+	}
+	transport.DialContext = dialer.DialContext
+
+	if tc.TLSHandshakeTimeoutMs > 0 {
+		transport.TLSHandshakeTimeout = msDuration(tc.TLSHandshakeTimeoutMs)
+	}
+	if tc.ResponseHeaderTimeoutMs > 0 {
+		transport.ResponseHeaderTimeout = msDuration(tc.ResponseHeaderTimeoutMs)
+	}
+	if tc.MaxIdleConns > 0 {
+		transport.MaxIdleConns = tc.MaxIdleConns
+	}
+	if tc.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = tc.MaxIdleConnsPerHost
+	}
+	if tc.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = tc.MaxConnsPerHost
+	}
+	if tc.IdleConnTimeoutMs > 0 {
+		transport.IdleConnTimeout = msDuration(tc.IdleConnTimeoutMs)
+	}
+
+	proxy, err := newProxy(tc)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+func newProxy(tc TransportConfig) (func(*http.Request) (*url.URL, error), error) {
+	switch {
+	case tc.DisableProxy:
+		return nil, nil
+	case tc.ProxyURL == "":
+		return http.ProxyFromEnvironment, nil
+	}
+
+	if _, err := url.Parse(tc.ProxyURL); err != nil {
+		return nil, invalidConfig(fmt.Errorf("invalid proxy url: %w", err))
+	}
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  tc.ProxyURL,
+		HTTPSProxy: tc.ProxyURL,
+		NoProxy:    strings.Join(tc.NoProxy, ","),
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func newTLSConfig(c TLSConfig) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[c.MinVersion]
+	if !ok {
+		return nil, invalidConfig(fmt.Errorf("unsupported tls min version %q", c.MinVersion))
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         minVersion,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CA != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(c.CA)) {
+			return nil, invalidConfig(errors.New("no certificate found in tls ca"))
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, invalidConfig(fmt.Errorf("invalid tls client certificate: %w", err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func invalidConfig(err error) error {
+	return commonerrs.Wrap(err, commonerrs.CodeInvalidArgument, "")
+}
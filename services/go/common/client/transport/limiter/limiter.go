@@ -0,0 +1,198 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+)
+
+// ErrLimited is the cause of the errors of calls exceeding the client-side limits, they are also
+// ErrResourceExhausted.
+var ErrLimited = errors.New("client-side limit exceeded")
+
+// Settings configures a Limiter.
+type Settings struct {
+	// RatePerSecond is the sustained rate of calls, 0 disables rate limiting.
+	RatePerSecond float64
+	// Burst is the number of calls allowed at once on top of the rate, 1 if unset.
+	Burst int
+	// MaxInFlight is the maximum number of concurrent calls, 0 disables the bulkhead.
+	MaxInFlight int
+	// Reject fails the calls exceeding the limits right away, instead of waiting as long as their context allows.
+	Reject bool
+}
+
+// Limiter limits the rate and the concurrency of the calls to a destination. A nil Limiter lets all
+// calls through.
+type Limiter struct {
+	name     string
+	reject   bool
+	bucket   *tokenBucket
+	inFlight chan struct{}
+}
+
+// New returns the limiter of given destination, nil if the settings disable it.
+func New(name string, settings Settings) *Limiter {
+	if settings.RatePerSecond <= 0 && settings.MaxInFlight <= 0 {
+		return nil
+	}
+
+	l := &Limiter{
+		name:   name,
+		reject: settings.Reject,
+	}
+	if settings.RatePerSecond > 0 {
+		burst := settings.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		l.bucket = newTokenBucket(settings.RatePerSecond, burst, time.Now)
+	}
+	if settings.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, settings.MaxInFlight)
+	}
+
+	return l
+}
+
+// Execute calls fn once the limits allow it, see Acquire.
+func (l *Limiter) Execute(ctx context.Context, fn func() error) error {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn()
+}
+
+// Acquire waits for a call to be allowed, the returned function must be called once the call is done.
+//
+// It fails with an error which is both ErrLimited and ErrResourceExhausted if the limiter rejects calls
+// exceeding the limits, or if the context is done before the call is allowed.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() {}
+	if l.inFlight != nil {
+		if err := l.acquireSlot(ctx); err != nil {
+			return nil, err
+		}
+		release = func() { <-l.inFlight }
+	}
+
+	if l.bucket != nil {
+		if err := l.waitToken(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+func (l *Limiter) acquireSlot(ctx context.Context) error {
+	select {
+	case l.inFlight <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.reject {
+		return l.limited("max in-flight calls reached")
+	}
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return l.limited("max in-flight calls reached before the context was done")
+	}
+}
+
+func (l *Limiter) waitToken(ctx context.Context) error {
+	maxWait := time.Duration(math.MaxInt64)
+	if l.reject {
+		maxWait = 0
+	} else if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+
+	wait, ok := l.bucket.reserve(maxWait)
+	if !ok {
+		return l.limited(fmt.Sprintf("rate limit reached, next call allowed in %s", wait))
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.bucket.cancel()
+		return l.limited("rate limit reached before the context was done")
+	}
+}
+
+func (l *Limiter) limited(reason string) error {
+	return commonerrs.Wrap(fmt.Errorf("%w: %s: %s", ErrLimited, l.name, reason), commonerrs.CodeResourceExhausted, "")
+}
+
+// tokenBucket is a token bucket whose tokens can be reserved ahead, making later callers wait longer.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    now,
+		tokens: float64(burst),
+		last:   now(),
+	}
+}
+
+// reserve takes a token if it is available within maxWait, and returns how long to wait for it.
+func (b *tokenBucket) reserve(maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+
+	b.tokens--
+	return wait, true
+}
+
+// cancel gives back a reserved token.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
@@ -0,0 +1,243 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	type step struct {
+		advance  time.Duration
+		maxWait  time.Duration
+		cancel   bool
+		wantWait time.Duration
+		wantOK   bool
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{
+			name:  "burst then rate",
+			rate:  10,
+			burst: 2,
+			steps: []step{
+				{maxWait: time.Second, wantOK: true},
+				{maxWait: time.Second, wantOK: true},
+				{maxWait: time.Second, wantWait: 100 * time.Millisecond, wantOK: true},
+				// Reserved tokens make later callers wait longer
+				{maxWait: time.Second, wantWait: 200 * time.Millisecond, wantOK: true},
+			},
+		},
+		{
+			name:  "refill over time",
+			rate:  10,
+			burst: 1,
+			steps: []step{
+				{wantOK: true},
+				{wantWait: 100 * time.Millisecond, wantOK: false},
+				{advance: 50 * time.Millisecond, wantWait: 50 * time.Millisecond, wantOK: false},
+				{advance: 50 * time.Millisecond, wantOK: true},
+			},
+		},
+		{
+			name:  "refill capped at burst",
+			rate:  10,
+			burst: 2,
+			steps: []step{
+				{advance: time.Hour, wantOK: true},
+				{wantOK: true},
+				{wantWait: 100 * time.Millisecond, wantOK: false},
+			},
+		},
+		{
+			name:  "rejected reservation takes no token",
+			rate:  1,
+			burst: 1,
+			steps: []step{
+				{wantOK: true},
+				{wantWait: time.Second, wantOK: false},
+				{wantWait: time.Second, wantOK: false},
+				{maxWait: 2 * time.Second, wantWait: time.Second, wantOK: true},
+			},
+		},
+		{
+			name:  "canceled reservation gives the token back",
+			rate:  1,
+			burst: 1,
+			steps: []step{
+				{wantOK: true},
+				{maxWait: 2 * time.Second, wantWait: time.Second, wantOK: true, cancel: true},
+				{maxWait: 2 * time.Second, wantWait: time.Second, wantOK: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			b := newTokenBucket(tt.rate, tt.burst, clock.now)
+
+			for i, s := range tt.steps {
+				clock.advance(s.advance)
+				wait, ok := b.reserve(s.maxWait)
+				if ok != s.wantOK || wait.Round(time.Millisecond) != s.wantWait {
+					t.Fatalf("step %d: reserve(%s) = (%s, %v), want (%s, %v)", i, s.maxWait, wait, ok, s.wantWait, s.wantOK)
+				}
+				if s.cancel {
+					b.cancel()
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_Reject(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+	}{
+		{name: "rate", settings: Settings{RatePerSecond: 1, Burst: 2, Reject: true}},
+		{name: "in flight", settings: Settings{MaxInFlight: 2, Reject: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New("test", tt.settings)
+
+			for i := 0; i < 2; i++ {
+				if _, err := l.Acquire(context.Background()); err != nil {
+					t.Fatalf("Acquire() %d error = %v", i, err)
+				}
+			}
+
+			_, err := l.Acquire(context.Background())
+			if !errors.Is(err, ErrLimited) || commonerrs.FromError(err).Code != commonerrs.CodeResourceExhausted {
+				t.Errorf("Acquire() error = %v, want ErrLimited with code %s", err, commonerrs.CodeResourceExhausted)
+			}
+		})
+	}
+}
+
+func TestLimiter_InFlightRelease(t *testing.T) {
+	l := New("test", Settings{MaxInFlight: 1})
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("Acquire() beyond the limit returned %v before the release", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("Acquire() after the release error = %v", err)
+	}
+}
+
+func TestLimiter_WaitUntilContextDone(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		ctx      func() (context.Context, context.CancelFunc)
+	}{
+		{
+			name:     "in flight canceled",
+			settings: Settings{MaxInFlight: 1},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+		},
+		{
+			name:     "rate deadline too short",
+			settings: Settings{RatePerSecond: 1},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+		},
+		{
+			name:     "rate canceled while waiting",
+			settings: Settings{RatePerSecond: 1},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New("test", tt.settings)
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			start := time.Now()
+			_, err := l.Acquire(ctx)
+			if !errors.Is(err, ErrLimited) {
+				t.Errorf("Acquire() error = %v, want ErrLimited", err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("Acquire() returned after %s, want once the context is done", elapsed)
+			}
+
+			// A canceled reservation gives its token back
+			if l.bucket != nil {
+				if wait, _ := l.bucket.reserve(0); wait > time.Second {
+					t.Errorf("next reservation waits %s, want at most 1s", wait)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	l := New("test", Settings{})
+	if l != nil {
+		t.Fatalf("New() with zero settings = %v, want nil", l)
+	}
+
+	called := false
+	if err := l.Execute(context.Background(), func() error {
+		called = true
+		return nil
+	}); err != nil || !called {
+		t.Errorf("Execute() = %v, called %v, want nil error and fn called", err, called)
+	}
+}
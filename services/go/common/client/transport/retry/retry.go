@@ -0,0 +1,299 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Default backoff parameters of a Policy.
+const (
+	DefaultMultiplier = 2.0
+	DefaultJitter     = 0.2
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Classifier decides whether an attempt failing with given error can be retried.
+type Classifier func(err error) bool
+
+// DefaultClassifier retries the errors of unavailable, overloaded or timed out destinations, e.g. HTTP
+// 429, 502, 503, 504 and gRPC Unavailable, ResourceExhausted, DeadlineExceeded, as well as transport
+// failures. Canceled calls and calls rejected by an open circuit breaker or a client-side limit are never
+// retried.
+func DefaultClassifier(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled {
+		return false
+	}
+	if errors.Is(err, breaker.ErrOpen) || errors.Is(err, limiter.ErrLimited) {
+		return false
+	}
+
+	switch commonerrs.FromError(err).Code {
+	case commonerrs.CodeUnavailable, commonerrs.CodeResourceExhausted, commonerrs.CodeTimeOut:
+		return true
+	default:
+		return false
+	}
+}
+
+// Opt is an option on a given Policy.
+type Opt func(p *Policy)
+
+// Policy decides whether and when failed calls are retried.
+type Policy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+	maxElapsed     time.Duration
+	classifier     Classifier
+}
+
+// NewPolicy returns a policy making at most maxAttempts attempts, the first retry waiting for the initial
+// backoff. The backoff is exponential with jitter, errors are classified by DefaultClassifier.
+func NewPolicy(maxAttempts int, initialBackoff time.Duration, opts ...Opt) *Policy {
+	p := &Policy{
+		maxAttempts:    maxAttempts,
+		initialBackoff: initialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		multiplier:     DefaultMultiplier,
+		jitter:         DefaultJitter,
+		classifier:     DefaultClassifier,
+	}
+
+	for _, o := range opts {
+		o(p)
+	}
+
+	return p
+}
+
+// NewPolicyFromMs returns the policy of a client config whose durations are in milliseconds, see NewPolicy.
+// A max backoff or a deadline of 0 is unset.
+func NewPolicyFromMs(maxAttempts, backoffMs, maxBackoffMs, deadlineMs int, opts ...Opt) *Policy {
+	var configOpts []Opt
+	if maxBackoffMs > 0 {
+		configOpts = append(configOpts, WithMaxBackoff(time.Duration(maxBackoffMs)*time.Millisecond))
+	}
+	if deadlineMs > 0 {
+		configOpts = append(configOpts, WithMaxElapsed(time.Duration(deadlineMs)*time.Millisecond))
+	}
+
+	return NewPolicy(maxAttempts, time.Duration(backoffMs)*time.Millisecond, append(configOpts, opts...)...)
+}
+
+// WithMaxBackoff returns an option that allows capping the backoff between attempts. It caps the delays
+// requested by the destination too, see Do.
+func WithMaxBackoff(maxBackoff time.Duration) Opt {
+	return func(p *Policy) {
+		p.maxBackoff = maxBackoff
+	}
+}
+
+// WithMultiplier returns an option that allows setting of the backoff growth between attempts.
+func WithMultiplier(multiplier float64) Opt {
+	return func(p *Policy) {
+		p.multiplier = multiplier
+	}
+}
+
+// WithJitter returns an option that allows setting of the random fraction, from 0 to 1, added to or removed
+// from the backoff so that clients do not retry in lockstep.
+func WithJitter(jitter float64) Opt {
+	return func(p *Policy) {
+		p.jitter = jitter
+	}
+}
+
+// WithMaxElapsed returns an option that allows setting of a total deadline across all attempts.
+func WithMaxElapsed(maxElapsed time.Duration) Opt {
+	return func(p *Policy) {
+		p.maxElapsed = maxElapsed
+	}
+}
+
+// WithClassifier returns an option that allows setting of the retryable errors.
+func WithClassifier(classifier Classifier) Opt {
+	return func(p *Policy) {
+		p.classifier = classifier
+	}
+}
+
+// Do calls fn until it succeeds, the error is not retryable or the attempts are exhausted, then returns
+// the last error. Attempts start at 1.
+//
+// Non-idempotent calls are only retried if the context allows it, see AllowNonIdempotent.
+//
+// A delay requested by the destination, see RetryAfter, replaces the backoff. If it is longer than the max
+// backoff, DefaultMaxBackoff if unset, the last error is returned right away rather than retrying earlier
+// than requested or blocking the caller for that long.
+func (p *Policy) Do(ctx context.Context, idempotent bool, fn func(ctx context.Context, attempt int) error) error {
+	if p.maxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.maxElapsed)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.maxAttempts || (!idempotent && !isNonIdempotentAllowed(ctx)) || isPermanent(err) || !p.classifier(err) {
+			return err
+		}
+
+		delay := p.Backoff(attempt)
+		if retryAfter, ok := RetryAfter(err); ok {
+			if retryAfter > p.maxRetryAfter() {
+				return err
+			}
+			delay = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// The next attempt could not complete in time
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// maxRetryAfter returns the longest delay requested by the destination which is waited for.
+func (p *Policy) maxRetryAfter() time.Duration {
+	if p.maxBackoff > 0 {
+		return p.maxBackoff
+	}
+	return DefaultMaxBackoff
+}
+
+// Backoff returns the delay before the retry following given attempt.
+func (p *Policy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.jitter > 0 {
+		backoff += backoff * p.jitter * (2*rand.Float64() - 1)
+	}
+	if p.maxBackoff > 0 && backoff > float64(p.maxBackoff) {
+		backoff = float64(p.maxBackoff)
+	}
+	return time.Duration(backoff)
+}
+
+// permanentError marks an error as not retryable, whatever its classification.
+type permanentError struct {
+	error
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.error
+}
+
+// Permanent returns an error which is never retried, e.g. when a request body cannot be sent again.
+func Permanent(err error) error {
+	return &permanentError{error: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+type nonIdempotentContextKey struct{}
+
+// AllowNonIdempotent returns a new Context in which non-idempotent calls, e.g. HTTP POSTs, are retried too.
+// Use it only for calls the destination deduplicates, e.g. by an idempotency key.
+func AllowNonIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nonIdempotentContextKey{}, true)
+}
+
+func isNonIdempotentAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(nonIdempotentContextKey{}).(bool)
+	return allowed
+}
+
+// IsIdempotentHTTPMethod reports whether an HTTP method is idempotent as per RFC 9110.
+func IsIdempotentHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfterError carries the delay requested by the destination before retrying.
+type retryAfterError struct {
+	error
+	after time.Duration
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.error
+}
+
+// WithRetryAfter returns an error carrying the delay before retrying, e.g. from a Retry-After header.
+func WithRetryAfter(err error, after time.Duration) error {
+	return &retryAfterError{error: err, after: after}
+}
+
+// RetryAfter returns the delay requested by the destination before retrying, given by WithRetryAfter or by
+// the RetryInfo detail of a gRPC status.
+func RetryAfter(err error) (time.Duration, bool) {
+	var retryAfterErr *retryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return retryAfterErr.after, true
+	}
+
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+				return info.GetRetryDelay().AsDuration(), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// ParseRetryAfter parses a Retry-After header value, either delay seconds or an HTTP date.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if date.Before(now) {
+			return 0, true
+		}
+		return date.Sub(now), true
+	}
+
+	return 0, false
+}
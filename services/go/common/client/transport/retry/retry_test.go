@@ -0,0 +1,288 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/breaker"
+	"github.com/phuchnd/eeaao/services/go/common/client/transport/limiter"
+	commonerrs "github.com/phuchnd/eeaao/services/go/common/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	errUnavailable = commonerrs.Wrap(errors.New("connection refused"), commonerrs.CodeUnavailable, "")
+	errNotFound    = commonerrs.Wrap(errors.New("missing"), commonerrs.CodeNotFound, "")
+)
+
+func TestPolicy_Do(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       *Policy
+		idempotent   bool
+		ctx          context.Context
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "succeeds after retries",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{errUnavailable, errUnavailable, nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "attempts exhausted",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{errUnavailable, errUnavailable, errUnavailable, nil},
+			wantAttempts: 3,
+			wantErr:      errUnavailable,
+		},
+		{
+			name:         "not retryable",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{errNotFound, nil},
+			wantAttempts: 1,
+			wantErr:      errNotFound,
+		},
+		{
+			name:         "permanent",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{Permanent(errUnavailable), nil},
+			wantAttempts: 1,
+			wantErr:      errUnavailable,
+		},
+		{
+			name:         "canceled",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{context.Canceled, nil},
+			wantAttempts: 1,
+			wantErr:      context.Canceled,
+		},
+		{
+			name:         "rejected by an open circuit",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{commonerrs.Wrap(breaker.ErrOpen, commonerrs.CodeUnavailable, ""), nil},
+			wantAttempts: 1,
+			wantErr:      breaker.ErrOpen,
+		},
+		{
+			name:         "rejected by a client-side limit",
+			policy:       NewPolicy(3, time.Millisecond),
+			idempotent:   true,
+			errs:         []error{commonerrs.Wrap(limiter.ErrLimited, commonerrs.CodeResourceExhausted, ""), nil},
+			wantAttempts: 1,
+			wantErr:      limiter.ErrLimited,
+		},
+		{
+			name:         "non-idempotent",
+			policy:       NewPolicy(3, time.Millisecond),
+			errs:         []error{errUnavailable, nil},
+			wantAttempts: 1,
+			wantErr:      errUnavailable,
+		},
+		{
+			name:         "non-idempotent allowed",
+			policy:       NewPolicy(3, time.Millisecond),
+			ctx:          AllowNonIdempotent(context.Background()),
+			errs:         []error{errUnavailable, nil},
+			wantAttempts: 2,
+		},
+		{
+			name:         "retry after within max backoff",
+			policy:       NewPolicy(3, time.Hour, WithMaxBackoff(time.Second)),
+			idempotent:   true,
+			errs:         []error{WithRetryAfter(errUnavailable, time.Millisecond), nil},
+			wantAttempts: 2,
+		},
+		{
+			name:         "retry after over max backoff",
+			policy:       NewPolicy(3, time.Millisecond, WithMaxBackoff(10*time.Millisecond)),
+			idempotent:   true,
+			errs:         []error{WithRetryAfter(errUnavailable, time.Hour), nil},
+			wantAttempts: 1,
+			wantErr:      errUnavailable,
+		},
+		{
+			name:         "backoff past the max elapsed",
+			policy:       NewPolicy(3, time.Hour, WithMaxElapsed(50*time.Millisecond), WithJitter(0)),
+			idempotent:   true,
+			errs:         []error{errUnavailable, nil},
+			wantAttempts: 1,
+			wantErr:      errUnavailable,
+		},
+		{
+			name:         "custom classifier",
+			policy:       NewPolicy(3, time.Millisecond, WithClassifier(func(err error) bool { return errors.Is(err, errNotFound) })),
+			idempotent:   true,
+			errs:         []error{errNotFound, nil},
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			attempts := 0
+			start := time.Now()
+			err := tt.policy.Do(ctx, tt.idempotent, func(ctx context.Context, attempt int) error {
+				attempts++
+				if attempt != attempts {
+					t.Errorf("attempt = %d, want %d", attempt, attempts)
+				}
+				return tt.errs[attempt-1]
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("Do() made %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Do() returned after %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestPolicy_DoContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	attempts := 0
+	err := NewPolicy(3, time.Hour).Do(ctx, true, func(context.Context, int) error {
+		attempts++
+		return errUnavailable
+	})
+
+	if attempts != 1 || !errors.Is(err, errUnavailable) {
+		t.Errorf("Do() = %v after %d attempts, want the last error after 1 attempt", err, attempts)
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *Policy
+		attempt int
+		want    time.Duration
+	}{
+		{
+			name:    "first retry",
+			policy:  NewPolicy(5, 100*time.Millisecond, WithJitter(0)),
+			attempt: 1,
+			want:    100 * time.Millisecond,
+		},
+		{
+			name:    "exponential",
+			policy:  NewPolicy(5, 100*time.Millisecond, WithJitter(0)),
+			attempt: 3,
+			want:    400 * time.Millisecond,
+		},
+		{
+			name:    "custom multiplier",
+			policy:  NewPolicy(5, 100*time.Millisecond, WithJitter(0), WithMultiplier(3)),
+			attempt: 3,
+			want:    900 * time.Millisecond,
+		},
+		{
+			name:    "capped",
+			policy:  NewPolicy(5, 100*time.Millisecond, WithJitter(0), WithMaxBackoff(250*time.Millisecond)),
+			attempt: 3,
+			want:    250 * time.Millisecond,
+		},
+		{
+			name:    "from config",
+			policy:  NewPolicyFromMs(5, 100, 250, 0, WithJitter(0)),
+			attempt: 3,
+			want:    250 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_BackoffJitter(t *testing.T) {
+	p := NewPolicy(5, 100*time.Millisecond, WithJitter(0.2))
+
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("Backoff(1) = %s, want within 20%% of 100ms", got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "overloaded").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(2 * time.Second),
+	})
+	if err != nil {
+		t.Fatalf("WithDetails() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "none", err: errUnavailable},
+		{name: "wrapped error", err: WithRetryAfter(errUnavailable, time.Second), want: time.Second, wantOK: true},
+		{name: "grpc retry info", err: st.Err(), want: 2 * time.Second, wantOK: true},
+		{name: "grpc without retry info", err: status.Error(codes.Unavailable, "down")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RetryAfter(tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RetryAfter() = (%s, %v), want (%s, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "empty", value: ""},
+		{name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "negative seconds", value: "-1"},
+		{name: "http date", value: "Mon, 01 Jan 2024 12:00:30 GMT", want: 30 * time.Second, wantOK: true},
+		{name: "past http date", value: "Mon, 01 Jan 2024 11:00:00 GMT", want: 0, wantOK: true},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseRetryAfter(%q) = (%s, %v), want (%s, %v)", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
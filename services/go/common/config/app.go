@@ -1,5 +1,10 @@
 package config
 
+import "github.com/phuchnd/eeaao/services/go/common/config/registry"
+
+// AppConfigName is the application configuration name
+const AppConfigName = "app"
+
 type AppType string
 
 const (
@@ -10,14 +15,26 @@ const (
 type AppEnv string
 
 const (
-	AppEnvLocal AppEnv  = "local"
-	AppEnvStg   AppEnv  = "stg"
-	AppEnvPrd   AppType = "prod"
+	AppEnvLocal AppEnv = "local"
+	AppEnvStg   AppEnv = "stg"
+	AppEnvPrd   AppEnv = "prod"
 )
 
 type AppConfig struct {
 	Type AppType
 	Port int
 	Name string
-	Env  AppEnv
+	// Env selects the `app-config.<env>.yaml` overlay, it can be set with the APP_ENV env var.
+	Env AppEnv `default:"local" env:"APP_ENV" validate:"oneof=local stg prod"`
+}
+
+// GetAppConfig returns the AppConfig of the provider. It panics if the service registered its own "app" config.
+func GetAppConfig(cp Provider) *AppConfig {
+	return cp.Get(AppConfigName).(*AppConfig)
+}
+
+// registerAppConfig registers the AppConfig in given registry, unless the service registered its own "app"
+// config already.
+func registerAppConfig(r *registry.Registry) {
+	_ = r.Register(AppConfigName, registry.NewStructConfig[AppConfig](AppConfigName))
 }
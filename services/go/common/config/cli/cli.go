@@ -0,0 +1,176 @@
+// Package cli implements the `config` subcommands that any service main can expose:
+//
+//	config dump             prints the effective configuration, sensitive values masked
+//	config schema           prints the JSON Schema of all registered configs
+//	config validate <file>  checks a config file against all registered configs
+//
+// A service main typically dispatches to it with:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "config" {
+//		if err := cli.New(cfgProvider).Run(os.Args[2:]); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//		os.Exit(0)
+//	}
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/phuchnd/eeaao/services/go/common/config"
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/viper"
+)
+
+const usage = `usage: config <command> [arguments]
+
+commands:
+  dump             print the effective configuration, sensitive values masked
+  schema           print the JSON Schema of all registered configs
+  validate <file>  check a config file against all registered configs`
+
+// ErrInvalidConfigFile is returned by `config validate` when the file is invalid.
+var ErrInvalidConfigFile = errors.New("invalid config file")
+
+// Option is an option on a CLI.
+type Option func(c *CLI)
+
+// CLI runs the `config` subcommands.
+type CLI struct {
+	provider config.Provider
+	registry *registry.Registry
+	out      io.Writer
+}
+
+// New creates a new CLI on top of given configuration provider.
+func New(provider config.Provider, opts ...Option) *CLI {
+	c := &CLI{
+		provider: provider,
+		registry: registry.Default(),
+		out:      os.Stdout,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
+// Run executes the subcommand given by args, e.g. []string{"validate", "app-config.yaml"}.
+func (c *CLI) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "dump":
+		return c.writeJSON(c.provider.DumpConfigs())
+	case "schema":
+		return c.writeJSON(config.JSONSchema(c.registry))
+	case "validate":
+		if len(args) != 2 {
+			return errors.New("usage: config validate <file>")
+		}
+		return c.validate(args[1])
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+// validate loads all registered configs from given file, without env vars nor resolving secret references, and
+// reports all problems at once.
+func (c *CLI) validate(file string) error {
+	fileViper := viper.New()
+	fileViper.SetConfigFile(file)
+	if err := fileViper.ReadInConfig(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfigFile, err)
+	}
+
+	var loadErr error
+	provider := config.NewProvider(
+		config.WithRegistry(c.registry),
+		config.WithWatchConfig(false),
+		config.WithoutEnv(),
+		config.WithUnresolvedSecrets(),
+		config.WithErrorHandler(func(err error) { loadErr = err }),
+		config.WithViperInitializer(func(v *viper.Viper) {
+			v.SetConfigFile(file)
+		}),
+	)
+
+	var errs []error
+	var warnings []string
+	c.registry.IterateConfigs(func(name string, cfg registry.Config) bool {
+		if _, err := provider.Lookup(name); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+	if loadErr != nil {
+		errs = append([]error{loadErr}, errs...)
+	}
+
+	for _, key := range fileViper.AllKeys() {
+		section, _, _ := strings.Cut(key, ".")
+		cfg := c.registry.GetConfig(section)
+		if cfg == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: no registered config %s", key, section))
+			continue
+		}
+		if lister, ok := cfg.(registry.KeyLister); ok && !isKnownKey(key, lister.Keys()) {
+			errs = append(errs, fmt.Errorf("%s: unknown key", key))
+		}
+	}
+
+	sort.Strings(warnings)
+	for _, w := range warnings {
+		_, _ = fmt.Fprintln(c.out, "warning:", w)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w %s:\n%w", ErrInvalidConfigFile, file, errors.Join(errs...))
+	}
+
+	_, _ = fmt.Fprintf(c.out, "%s is valid\n", file)
+	return nil
+}
+
+// isKnownKey reports whether key is one of the known keys or a parent of one of them.
+func isKnownKey(key string, known []string) bool {
+	for _, k := range known {
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *CLI) writeJSON(value interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+// WithRegistry returns an option that allows using an isolated registry instead of the default one.
+func WithRegistry(r *registry.Registry) Option {
+	return func(c *CLI) {
+		c.registry = r
+	}
+}
+
+// WithOutput returns an option that allows writing to given writer instead of stdout.
+func WithOutput(out io.Writer) Option {
+	return func(c *CLI) {
+		c.out = out
+	}
+}
@@ -1,41 +1,44 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"reflect"
-	"strings"
 	"sync"
 
-	"github.com/fatih/structs"
-	"github.com/iancoleman/strcase"
 	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
-const (
-	MaskedTagOption = "masked"
-	MaskedValue     = "******"
-)
-
-var (
-	defaultMaskedFieldNames = map[string]bool{
-		"password": true,
-		"key":      true,
-		"secret":   true,
-	}
-)
-
 // Provider represents the configuration provider.
 //
 //go:generate mockery --name=Provider --case=snake --disable-version-string
 type Provider interface {
 	// Get returns a configuration with given name.
+	// It panics if the configuration does not exist or is invalid, use Lookup or GetAs to handle the error instead.
 	Get(name string) interface{}
 
-	// DumpConfigs compiles all registered config values into a map.
+	// Lookup returns a configuration with given name, or an error if it does not exist or is invalid.
+	Lookup(name string) (interface{}, error)
+
+	// DumpConfigs compiles all registered config values into a map, masking sensitive values.
 	DumpConfigs() map[string]interface{}
+
+	// Subscribe registers a handler that is called whenever the configuration with given name changes.
+	Subscribe(name string, handler ChangeHandler)
+
+	// Source returns the layer the effective value of given key came from.
+	Source(key string) KeySource
+
+	// Close stops watching the config files, subscribers are no longer notified.
+	Close() error
 }
 
+// ChangeHandler is a function that is notified with the old and new values of a changed configuration.
+type ChangeHandler func(old, new interface{})
+
 // ProviderOpt is an option on a given Provider.
 type ProviderOpt func(impl *providerImpl)
 
@@ -44,120 +47,374 @@ type ViperInitializer func(v *viper.Viper)
 
 // providerImpl implements Provider interface.
 type providerImpl struct {
+	mu               sync.RWMutex
 	viper            *viper.Viper
 	viperInitializer ViperInitializer
+	registry         *registry.Registry
+	flags            *pflag.FlagSet
+	layers           *fileLayers
+	memory           *viper.Viper
+	secrets          *secretStore
+	maskingPolicy    MaskingPolicy
+
+	watchConfig  bool
+	ignoreEnv    bool
+	errorHandler func(err error)
+	watcher      *fileWatcher
+	closed       bool
+	values       map[string]interface{}
+	subscribers  map[string][]ChangeHandler
 
 	once *sync.Once
 }
 
 // NewProvider creates a new instance of configuration provider.
+//
+// The AppConfig is registered in the registry of the provider, unless an "app" config is registered already.
 func NewProvider(opts ...ProviderOpt) Provider {
 	provider := &providerImpl{
-		viper: viper.New(),
 		viperInitializer: func(v *viper.Viper) {
 			v.SetConfigName("app-config") // name of config file (without extension)
 			v.SetConfigType("yaml")
 			v.AddConfigPath("$APP_CONFIG_DIR")
 			v.AddConfigPath(".")
 			v.AddConfigPath("$HOME")
-			v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-			v.SetEnvPrefix("APP")
+			v.SetEnvKeyReplacer(envKeyReplacer)
+			v.SetEnvPrefix(EnvPrefix)
 			v.AutomaticEnv()
 		},
-		once: &sync.Once{},
+		watchConfig:   true,
+		errorHandler:  func(err error) {},
+		values:        map[string]interface{}{},
+		subscribers:   map[string][]ChangeHandler{},
+		secrets:       newSecretStore(),
+		registry:      registry.Default(),
+		maskingPolicy: DefaultMaskingPolicy(),
+		once:          &sync.Once{},
 	}
 
 	for _, o := range opts {
 		o(provider)
 	}
+	registerAppConfig(provider.registry)
 
 	return provider
 }
 
 func (impl *providerImpl) initializeOnce() {
 	impl.once.Do(func() {
-		registry.SetDefaultConfigs(impl.viper)
-		impl.viperInitializer(impl.viper)
-		_ = impl.viper.ReadInConfig()
+		var err error
+		impl.viper, impl.layers, err = impl.load()
+		if err != nil {
+			impl.errorHandler(fmt.Errorf("load configuration: %w", err))
+		}
+
+		impl.registry.IterateConfigs(func(name string, config registry.Config) bool {
+			impl.values[name] = config.Get(impl.viper)
+			return true
+		})
+
+		if impl.watchConfig {
+			impl.watch()
+		}
 	})
 }
 
-func (impl *providerImpl) Get(name string) interface{} {
+// load creates a new Viper object populated with all configuration layers: defaults, the base
+// config file, the env overlay file, env vars and flags. Secret references are then resolved.
+//
+// A missing config file is not an error, the defaults and env vars still apply.
+func (impl *providerImpl) load() (*viper.Viper, *fileLayers, error) {
+	v := viper.New()
+	if impl.ignoreEnv {
+		setDefaultsWithoutEnv(v, impl.registry)
+	} else {
+		impl.registry.SetDefaultConfigs(v)
+	}
+	impl.viperInitializer(v)
+	if impl.flags != nil {
+		if err := v.BindPFlags(impl.flags); err != nil {
+			return v, &fileLayers{}, err
+		}
+	}
+
+	layers := &fileLayers{}
+	if err := v.ReadInConfig(); err == nil {
+		if layers, err = loadFileLayers(v); err != nil {
+			return v, layers, err
+		}
+	} else if !isConfigFileNotFound(err) {
+		return v, layers, err
+	}
+
+	secretFiles, err := impl.secrets.resolveAll(v, impl.registry)
+	layers.secretFiles = secretFiles
+
+	return v, layers, err
+}
+
+func isConfigFileNotFound(err error) bool {
+	return errors.As(err, &viper.ConfigFileNotFoundError{}) || errors.Is(err, fs.ErrNotExist)
+}
+
+// watch starts watching the config files in use, if any.
+func (impl *providerImpl) watch() {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	if impl.closed {
+		return
+	}
+	if impl.watcher == nil {
+		if len(impl.layers.files()) == 0 {
+			return
+		}
+
+		watcher, err := newFileWatcher(impl.reload)
+		if err != nil {
+			impl.errorHandler(err)
+			return
+		}
+		impl.watcher = watcher
+	}
+
+	for _, file := range impl.layers.files() {
+		if err := impl.watcher.add(file); err != nil {
+			impl.errorHandler(err)
+		}
+	}
+}
+
+// reload re-reads the config files and secrets, re-runs all registered getters and notifies
+// subscribers of the changed configurations.
+//
+// The last good configuration is kept if a config file cannot be read, was emptied, e.g. while it is
+// being rewritten, or if any configuration is invalid.
+func (impl *providerImpl) reload() {
+	v, layers, err := impl.load()
+	if err != nil {
+		impl.errorHandler(fmt.Errorf("reload configuration: %w", err))
+		return
+	}
+
+	impl.mu.RLock()
+	current := impl.layers
+	impl.mu.RUnlock()
+	if layers.baseFile == "" && current.baseFile != "" {
+		impl.errorHandler(fmt.Errorf("reload configuration: config file %s is missing", current.baseFile))
+		return
+	}
+	if file := emptiedFile(current, layers); file != "" {
+		impl.errorHandler(fmt.Errorf("reload configuration: config file %s is empty", file))
+		return
+	}
+
+	newValues := map[string]interface{}{}
+	var errs []error
+	impl.registry.IterateConfigs(func(name string, config registry.Config) bool {
+		value, err := config.Load(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid configuration %s: %w", name, err))
+		}
+		newValues[name] = value
+		return true
+	})
+	if len(errs) > 0 {
+		impl.errorHandler(fmt.Errorf("reload configuration: %w", errors.Join(errs...)))
+		return
+	}
+
+	type change struct {
+		old, new interface{}
+		handlers []ChangeHandler
+	}
+	var changes []change
+
+	impl.mu.Lock()
+	impl.viper = v
+	impl.layers = layers
+	for name, newValue := range newValues {
+		oldValue := impl.values[name]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		impl.values[name] = newValue
+		if handlers := impl.subscribers[name]; len(handlers) > 0 {
+			changes = append(changes, change{
+				old:      oldValue,
+				new:      newValue,
+				handlers: append([]ChangeHandler(nil), handlers...),
+			})
+		}
+	}
+	impl.mu.Unlock()
+
+	// The env may have changed and point to another overlay file.
+	impl.watch()
+
+	for _, c := range changes {
+		for _, handler := range c.handlers {
+			handler(c.old, c.new)
+		}
+	}
+}
+
+// Close stops watching the config files.
+func (impl *providerImpl) Close() error {
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	impl.closed = true
+	if impl.watcher == nil {
+		return nil
+	}
+
+	err := impl.watcher.close()
+	impl.watcher = nil
+	return err
+}
+
+// currentViper returns the Viper object holding the latest configuration.
+func (impl *providerImpl) currentViper() *viper.Viper {
 	impl.initializeOnce()
 
-	config := registry.GetConfig(name)
+	impl.mu.RLock()
+	defer impl.mu.RUnlock()
+
+	return impl.viper
+}
+
+func (impl *providerImpl) Get(name string) interface{} {
+	value, err := impl.Lookup(name)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return value
+}
+
+// Lookup returns a configuration with given name, or an error if it does not exist or is invalid.
+func (impl *providerImpl) Lookup(name string) (interface{}, error) {
+	v := impl.currentViper()
+
+	config := impl.registry.GetConfig(name)
 	if config == nil {
-		panic(fmt.Sprintf("cannot find configuration with name %s", name))
+		return nil, fmt.Errorf("cannot find configuration with name %s: %w", name, registry.ErrConfigNotFound)
+	}
+
+	value, err := config.Load(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration %s:\n%w", name, err)
+	}
+
+	return value, nil
+}
+
+// GetAs returns a configuration with given name as T, e.g. GetAs[*logging.Config](provider, logging.ConfigName).
+func GetAs[T any](provider Provider, name string) (T, error) {
+	var zero T
+
+	value, err := provider.Lookup(name)
+	if err != nil {
+		return zero, err
 	}
 
-	return config.Get(impl.viper)
+	typed, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("configuration %s is %T, not %T", name, value, zero)
+	}
+
+	return typed, nil
+}
+
+// Subscribe registers a handler that is called whenever the configuration with given name changes.
+//
+// Handlers are called synchronously from the watcher goroutine, they should not block.
+func (impl *providerImpl) Subscribe(name string, handler ChangeHandler) {
+	impl.initializeOnce()
+
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+
+	impl.subscribers[name] = append(impl.subscribers[name], handler)
 }
 
 // DumpConfigs compiles all registered config values into a map.
 func (impl *providerImpl) DumpConfigs() map[string]interface{} {
-	impl.initializeOnce()
+	v := impl.currentViper()
 
 	values := map[string]interface{}{}
 
-	registry.IterateConfigs(func(name string, config registry.Config) bool {
-		values[name] = impl.formatConfig(config.Get(impl.viper))
+	impl.registry.IterateConfigs(func(name string, config registry.Config) bool {
+		values[name] = impl.maskingPolicy.Mask(config.Get(v))
 		return true
 	})
 
 	return values
 }
 
-// formatConfig ensures the config value is formatted and masked.
-func (impl *providerImpl) formatConfig(value interface{}) interface{} {
-	if !structs.IsStruct(value) {
-		return value
+// WithViperInitializer returns an option that allows setting of viper initializer in the provider.
+func WithViperInitializer(viperInitializer ViperInitializer) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.viperInitializer = viperInitializer
 	}
+}
 
-	v := structs.New(value)
-	fields := v.Fields()
-
-	for _, f := range fields {
-		impl.formatConfigField(f)
+// WithWatchConfig returns an option that enables or disables hot-reloading of the config file.
+//
+// Watching is enabled by default.
+func WithWatchConfig(watch bool) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.watchConfig = watch
 	}
-
-	return v.Map()
 }
 
-func (impl *providerImpl) formatConfigField(f *structs.Field) {
-	if (f.Kind() == reflect.Struct || f.Kind() == reflect.Ptr) && f.Fields() != nil {
-		for _, childF := range f.Fields() {
-			impl.formatConfigField(childF)
-		}
+// WithErrorHandler returns an option that allows handling of errors raised while loading or reloading the configuration.
+func WithErrorHandler(handler func(err error)) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.errorHandler = handler
 	}
+}
 
-	tagName, tagOpts := parseTag(f.Tag(structs.DefaultTagName))
-
-	if tagOpts.Has(MaskedTagOption) || isMaskedFieldNameOrTag(tagName) || isMaskedFieldNameOrTag(f.Name()) {
-		if f.Kind() == reflect.String {
-			_ = f.Set(MaskedValue)
-		} else {
-			panic(fmt.Errorf("masked field '%s' must be string", f.Name()))
-		}
+// WithFlags returns an option that binds given command-line flags, named after the config keys
+// (e.g. --logging.level), as the layer with the highest precedence.
+func WithFlags(flags *pflag.FlagSet) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.flags = flags
 	}
 }
 
-func isMaskedFieldNameOrTag(name string) bool {
-	formatted := strcase.ToSnake(name)
-	parts := strings.Split(formatted, "_")
+// WithRegistry returns an option that allows using an isolated registry instead of the default one.
+func WithRegistry(r *registry.Registry) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.registry = r
+	}
+}
 
-	for _, v := range parts {
-		_, contained := defaultMaskedFieldNames[v]
-		if contained {
-			return true
-		}
+// WithoutEnv returns an option that prevents the provider from reading env vars: the env tags of the
+// registered configs are ignored, and `secret://env/...` references fail unless a resolver is set for the
+// env scheme afterwards.
+//
+// The default viper initializer enables automatic env vars, replace it too, see WithViperInitializer.
+func WithoutEnv() ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.ignoreEnv = true
+		impl.secrets.resolvers["env"] = &ignoredEnvSecretResolver{}
 	}
+}
 
-	return false
+// WithUnresolvedSecrets returns an option that only checks that secret references are well-formed, with a
+// known scheme and a path, and leaves them unresolved, e.g. to validate a config file before deploying it.
+func WithUnresolvedSecrets() ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.secrets.unresolved = true
+	}
 }
 
-// WithViperInitializer returns an option that allows setting of viper initializer in the provider.
-func WithViperInitializer(viperInitializer ViperInitializer) ProviderOpt {
+// WithMaskingPolicy returns an option that allows setting of the policy used to mask DumpConfigs values.
+func WithMaskingPolicy(policy MaskingPolicy) ProviderOpt {
 	return func(impl *providerImpl) {
-		impl.viperInitializer = viperInitializer
+		impl.maskingPolicy = policy
 	}
 }
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of the env vars automatically bound to config keys,
+// e.g. APP_LOGGING_LEVEL for logging.level.
+const EnvPrefix = "APP"
+
+var envKeyReplacer = strings.NewReplacer(".", "_")
+
+// Layer is a configuration layer a value can come from, in the order of increasing precedence:
+// default, base file, env overlay file, env var, flag. The values of a provider from NewProviderFromMap
+// come from the memory layer.
+type Layer string
+
+const (
+	LayerNone        Layer = ""
+	LayerDefault     Layer = "default"
+	LayerMemory      Layer = "memory"
+	LayerBaseFile    Layer = "base_file"
+	LayerOverlayFile Layer = "overlay_file"
+	LayerEnv         Layer = "env"
+	LayerFlag        Layer = "flag"
+)
+
+// KeySource describes where the effective value of a config key came from.
+type KeySource struct {
+	Layer Layer
+	// File is the config file path for file layers.
+	File string
+	// Name is the env var or flag name for env and flag layers.
+	Name string
+}
+
+// fileLayers holds the config files that have been merged into a Viper object.
+type fileLayers struct {
+	env         AppEnv
+	baseFile    string
+	base        *viper.Viper
+	overlayFile string
+	overlay     *viper.Viper
+	secretFiles []string
+}
+
+// files returns the paths of the config and secret files that should be watched for changes.
+func (l *fileLayers) files() []string {
+	var files []string
+	if l.baseFile != "" {
+		files = append(files, l.baseFile)
+	}
+	if l.overlayFile != "" {
+		files = append(files, l.overlayFile)
+	}
+	files = append(files, l.secretFiles...)
+
+	return files
+}
+
+// loadFileLayers merges the `<name>.<env>.<ext>` overlay next to the base config file into v.
+//
+// The env is taken from `app.env`, which can be set by the base file, the APP_ENV env var or a flag.
+// A missing overlay file is not an error.
+func loadFileLayers(v *viper.Viper) (*fileLayers, error) {
+	layers := &fileLayers{
+		env:      AppEnv(v.GetString(AppConfigName + ".env")),
+		baseFile: v.ConfigFileUsed(),
+	}
+
+	base, err := readConfigFile(layers.baseFile)
+	if err != nil {
+		return layers, err
+	}
+	layers.base = base
+
+	if layers.env == "" {
+		return layers, nil
+	}
+
+	ext := filepath.Ext(layers.baseFile)
+	layers.overlayFile = strings.TrimSuffix(layers.baseFile, ext) + "." + string(layers.env) + ext
+	if _, err := os.Stat(layers.overlayFile); os.IsNotExist(err) {
+		return layers, nil
+	}
+
+	overlay, err := readConfigFile(layers.overlayFile)
+	if err != nil {
+		return layers, err
+	}
+	layers.overlay = overlay
+
+	return layers, v.MergeConfigMap(overlay.AllSettings())
+}
+
+// emptiedFile returns the config file of a layer which had values and has none anymore, if any. Removed
+// overlay files are not reported.
+func emptiedFile(old, new *fileLayers) string {
+	if isEmptyLayer(new.base) && !isEmptyLayer(old.base) {
+		return new.baseFile
+	}
+	if new.overlay != nil && new.overlayFile == old.overlayFile && isEmptyLayer(new.overlay) && !isEmptyLayer(old.overlay) {
+		return new.overlayFile
+	}
+	return ""
+}
+
+func isEmptyLayer(layer *viper.Viper) bool {
+	return layer == nil || len(layer.AllKeys()) == 0
+}
+
+func readConfigFile(file string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+
+	return v, v.ReadInConfig()
+}
+
+// Source returns the layer the effective value of given key came from.
+func (impl *providerImpl) Source(key string) KeySource {
+	impl.initializeOnce()
+
+	impl.mu.RLock()
+	v, layers := impl.viper, impl.layers
+	impl.mu.RUnlock()
+
+	key = strings.ToLower(key)
+
+	if impl.flags != nil {
+		if f := impl.flags.Lookup(key); f != nil && f.Changed {
+			return KeySource{Layer: LayerFlag, Name: f.Name}
+		}
+	}
+
+	for _, name := range envVarNames(impl.registry, key) {
+		// Viper ignores empty env vars.
+		if !impl.ignoreEnv && os.Getenv(name) != "" {
+			return KeySource{Layer: LayerEnv, Name: name}
+		}
+	}
+
+	if layers.overlay != nil && layers.overlay.IsSet(key) {
+		return KeySource{Layer: LayerOverlayFile, File: layers.overlayFile}
+	}
+	if layers.base != nil && layers.base.IsSet(key) {
+		return KeySource{Layer: LayerBaseFile, File: layers.baseFile}
+	}
+	if impl.memory != nil && impl.memory.IsSet(key) {
+		return KeySource{Layer: LayerMemory}
+	}
+	if v.IsSet(key) {
+		return KeySource{Layer: LayerDefault}
+	}
+
+	return KeySource{Layer: LayerNone}
+}
+
+// envVarNames returns the env vars bound to given key, explicit bindings first.
+//
+// The automatically bound name assumes the default env prefix and key replacer.
+func envVarNames(reg *registry.Registry, key string) []string {
+	var names []string
+
+	reg.IterateConfigs(func(name string, config registry.Config) bool {
+		if binder, ok := config.(registry.EnvBinder); ok {
+			if env, ok := binder.EnvBindings()[key]; ok {
+				names = append(names, env)
+			}
+		}
+		return true
+	})
+
+	return append(names, EnvPrefix+"_"+strings.ToUpper(envKeyReplacer.Replace(key)))
+}
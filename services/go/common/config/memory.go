@@ -0,0 +1,53 @@
+package config
+
+import (
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/viper"
+)
+
+// NewProviderFromMap creates a configuration provider backed only by given in-memory values,
+// keyed like the config file, e.g. {"logging": {"level": "info"}}.
+//
+// No config file or env var is read, so tests get an isolated configuration: the env tags of the
+// registered configs are ignored, and `secret://env/...` references fail unless a resolver is set for
+// the env scheme, e.g. WithSecretResolver("env", NewStaticSecretResolver(...)). Combine it with
+// WithRegistry to also isolate the registered configs.
+func NewProviderFromMap(values map[string]interface{}, opts ...ProviderOpt) Provider {
+	opts = append([]ProviderOpt{
+		WithWatchConfig(false),
+		WithoutEnv(),
+		withMemoryLayer(values),
+	}, opts...)
+
+	return NewProvider(opts...)
+}
+
+// withMemoryLayer returns an option that merges given values into the config, reported as LayerMemory.
+func withMemoryLayer(values map[string]interface{}) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.memory = viper.New()
+		_ = impl.memory.MergeConfigMap(values)
+		impl.viperInitializer = func(v *viper.Viper) {
+			_ = v.MergeConfigMap(values)
+		}
+	}
+}
+
+// setDefaultsWithoutEnv sets the defaults of the registered configs without binding their env vars.
+// Configs which cannot describe their fields set their defaults themselves.
+func setDefaultsWithoutEnv(v *viper.Viper, reg *registry.Registry) {
+	reg.IterateConfigs(func(name string, config registry.Config) bool {
+		describer, ok := config.(registry.FieldDescriber)
+		if !ok {
+			config.SetDefault(v)
+			return true
+		}
+
+		for _, f := range describer.Fields() {
+			if f.Default != nil {
+				v.SetDefault(f.Key, *f.Default)
+			}
+		}
+		return true
+	})
+}
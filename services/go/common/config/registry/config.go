@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"reflect"
+
 	"github.com/spf13/viper"
 )
 
@@ -17,6 +19,40 @@ type ConfigOpt func(c *configImpl)
 type Config interface {
 	SetDefault(v *viper.Viper)
 	Get(v *viper.Viper) interface{}
+
+	// Load retrieves the config values, reporting any binding or validation error.
+	Load(v *viper.Viper) (interface{}, error)
+}
+
+// EnvBinder is implemented by configs that bind keys to explicit env vars.
+type EnvBinder interface {
+	// EnvBindings returns the env var names keyed by config key.
+	EnvBindings() map[string]string
+}
+
+// KeyLister is implemented by configs that know all the keys they read.
+type KeyLister interface {
+	// Keys returns the full viper keys read by the config.
+	Keys() []string
+}
+
+// FieldDescriber is implemented by configs that can describe their fields, e.g. to export a schema.
+type FieldDescriber interface {
+	// Fields returns the descriptions of all bindable fields.
+	Fields() []FieldDescription
+}
+
+// FieldDescription describes a bindable config field as declared by its struct tags.
+type FieldDescription struct {
+	// Key is the full viper key.
+	Key string
+	// Index is the field index in the config struct, as used by reflect.Value.FieldByIndex.
+	Index []int
+	Type  reflect.Type
+	// Default is the raw default tag, nil if there is none.
+	Default  *string
+	Env      string
+	Validate string
 }
 
 // configImpl implements Config.
@@ -51,6 +87,11 @@ func (c *configImpl) Get(v *viper.Viper) interface{} {
 	return c.getFn(v)
 }
 
+// Load retrieves the config values by delegating to its value getter function, it never fails.
+func (c *configImpl) Load(v *viper.Viper) (interface{}, error) {
+	return c.getFn(v), nil
+}
+
 // WithSetDefault is a ConfigOpt that allows a Config to specify its default values.
 func WithSetDefault(setDefaultFn SetDefaultConfigFunc) ConfigOpt {
 	return func(c *configImpl) {
@@ -1,63 +1,118 @@
 package registry
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/spf13/viper"
 )
 
 var (
-	mu      sync.RWMutex
-	configs = make(map[string]Config)
+	ErrConfigAlreadyRegistered = errors.New("config already registered")
+	ErrConfigNotFound          = errors.New("config not found")
 )
 
+// defaultRegistry is the registry used by the package-level functions, configs register themselves in it on init.
+var defaultRegistry = New()
+
 // IteratorFunc is a function that is used to iterate through all registered configs.
 //
 // The iterator should return `true` if it wants to move to the next config, and `false` otherwise.
 type IteratorFunc func(name string, config Config) bool
 
-// RegisterConfig registers a Config with given name.
-func RegisterConfig(name string, config Config) {
-	mu.Lock()
-	defer mu.Unlock()
+// Registry is a set of named configs.
+type Registry struct {
+	mu      sync.RWMutex
+	configs map[string]Config
+}
 
-	_, ok := configs[name]
-	if ok {
-		panic(fmt.Sprintf("a config with name %s already exist", name))
+// New creates a new empty Registry.
+func New() *Registry {
+	return &Registry{
+		configs: make(map[string]Config),
 	}
+}
 
-	configs[name] = config
+// Default returns the registry used by the package-level functions.
+func Default() *Registry {
+	return defaultRegistry
 }
 
-// GetConfig returns a Config with given name.
-func GetConfig(name string) Config {
-	mu.RLock()
-	defer mu.RUnlock()
+// Register registers a Config with given name, it fails if the name is already taken.
+func (r *Registry) Register(name string, config Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	config, ok := configs[name]
-	if !ok {
-		return nil
+	if _, ok := r.configs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrConfigAlreadyRegistered, name)
 	}
 
-	return config
+	r.configs[name] = config
+	return nil
 }
 
-// SetDefaultConfigs attempts to run all set-default functions of all registered configs.
-func SetDefaultConfigs(v *viper.Viper) {
-	mu.RLock()
-	defer mu.RUnlock()
+// GetConfig returns a Config with given name, or nil if it does not exist.
+func (r *Registry) GetConfig(name string) Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.configs[name]
+}
 
-	for _, config := range configs {
+// SetDefaultConfigs attempts to run all set-default functions of all registered configs.
+func (r *Registry) SetDefaultConfigs(v *viper.Viper) {
+	r.IterateConfigs(func(name string, config Config) bool {
 		config.SetDefault(v)
-	}
+		return true
+	})
 }
 
-// IterateConfigs iterates through all registered configs.
-func IterateConfigs(iterator IteratorFunc) {
-	for k, v := range configs {
-		if moveNext := iterator(k, v); !moveNext {
+// IterateConfigs iterates through all registered configs in the order of their names.
+//
+// The iterator runs on a snapshot, so it may register new configs.
+func (r *Registry) IterateConfigs(iterator IteratorFunc) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	configs := make(map[string]Config, len(r.configs))
+	for name, config := range r.configs {
+		configs[name] = config
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		if moveNext := iterator(name, configs[name]); !moveNext {
 			return
 		}
 	}
 }
+
+// RegisterConfig registers a Config with given name in the default registry.
+//
+// It is meant to be called from init() and panics if the name is already taken,
+// use Registry.Register to handle the error instead.
+func RegisterConfig(name string, config Config) {
+	if err := defaultRegistry.Register(name, config); err != nil {
+		panic(err.Error())
+	}
+}
+
+// GetConfig returns a Config with given name from the default registry.
+func GetConfig(name string) Config {
+	return defaultRegistry.GetConfig(name)
+}
+
+// SetDefaultConfigs attempts to run all set-default functions of all configs in the default registry.
+func SetDefaultConfigs(v *viper.Viper) {
+	defaultRegistry.SetDefaultConfigs(v)
+}
+
+// IterateConfigs iterates through all configs in the default registry.
+func IterateConfigs(iterator IteratorFunc) {
+	defaultRegistry.IterateConfigs(iterator)
+}
@@ -0,0 +1,409 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Struct tags understood by NewStructConfig.
+//
+//	type Config struct {
+//		Level   string        `config:"level" default:"debug" env:"LOG_LEVEL" validate:"required,oneof=debug info warn error"`
+//		Timeout time.Duration `default:"5s" validate:"min=1s,max=1m"`
+//	}
+//
+// The key defaults to the snake-cased field name, a key of "-" skips the field.
+const (
+	KeyTagName      = "config"
+	DefaultTagName  = "default"
+	EnvTagName      = "env"
+	ValidateTagName = "validate"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// structConfig implements Config by binding a struct of type T from viper using struct tags.
+type structConfig[T any] struct {
+	name   string
+	fields []*structField
+}
+
+// structField is a bindable leaf field of the config struct.
+type structField struct {
+	index        []int
+	key          string
+	typ          reflect.Type
+	defaultValue *string
+	env          string
+	validate     string
+	rules        []validationRule
+}
+
+// validationRule validates a field value, returning a descriptive error if it is invalid.
+type validationRule func(value reflect.Value) error
+
+// NewStructConfig returns a new config section that binds a *T from the viper keys under given name.
+//
+// The keys, defaults, env vars and validation rules are declared with struct tags, see KeyTagName.
+// It panics if T is not a struct or if its tags are malformed.
+func NewStructConfig[T any](name string) Config {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config %s: %s is not a struct", name, typ))
+	}
+
+	fields, err := parseStructFields(typ, name, nil)
+	if err != nil {
+		panic(fmt.Sprintf("config %s: %s", name, err))
+	}
+
+	return &structConfig[T]{
+		name:   name,
+		fields: fields,
+	}
+}
+
+// SetDefault sets the default values and binds the env vars declared in the struct tags.
+func (c *structConfig[T]) SetDefault(v *viper.Viper) {
+	for _, f := range c.fields {
+		if f.defaultValue != nil {
+			v.SetDefault(f.key, *f.defaultValue)
+		}
+		if f.env != "" {
+			_ = v.BindEnv(f.key, f.env)
+		}
+	}
+}
+
+// Keys returns the full viper keys of all bindable fields.
+func (c *structConfig[T]) Keys() []string {
+	keys := make([]string, 0, len(c.fields))
+	for _, f := range c.fields {
+		keys = append(keys, f.key)
+	}
+
+	return keys
+}
+
+// Fields returns the descriptions of all bindable fields.
+func (c *structConfig[T]) Fields() []FieldDescription {
+	descriptions := make([]FieldDescription, 0, len(c.fields))
+	for _, f := range c.fields {
+		descriptions = append(descriptions, FieldDescription{
+			Key:      f.key,
+			Index:    f.index,
+			Type:     f.typ,
+			Default:  f.defaultValue,
+			Env:      f.env,
+			Validate: f.validate,
+		})
+	}
+
+	return descriptions
+}
+
+// EnvBindings returns the keys bound to explicit env vars by the env tag.
+func (c *structConfig[T]) EnvBindings() map[string]string {
+	bindings := map[string]string{}
+	for _, f := range c.fields {
+		if f.env != "" {
+			bindings[f.key] = f.env
+		}
+	}
+
+	return bindings
+}
+
+// Get binds the config values, ignoring any binding or validation error.
+func (c *structConfig[T]) Get(v *viper.Viper) interface{} {
+	value, _ := c.Load(v)
+	return value
+}
+
+// Load binds and validates the config values, all errors are aggregated into one.
+func (c *structConfig[T]) Load(v *viper.Viper) (interface{}, error) {
+	value := new(T)
+	root := reflect.ValueOf(value).Elem()
+
+	var errs []error
+	for _, f := range c.fields {
+		fieldValue := root.FieldByIndex(f.index)
+		if err := decodeValue(v.Get(f.key), fieldValue); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+			continue
+		}
+		for _, rule := range f.rules {
+			if err := rule(fieldValue); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+			}
+		}
+	}
+
+	return value, errors.Join(errs...)
+}
+
+func parseStructFields(typ reflect.Type, prefix string, index []int) ([]*structField, error) {
+	var fields []*structField
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Tag.Get(KeyTagName)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strcase.ToSnake(sf.Name)
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if sf.Type.Kind() == reflect.Struct {
+			nested, err := parseStructFields(sf.Type, key, fieldIndex)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+			continue
+		}
+
+		if !isSupportedType(sf.Type) {
+			return nil, fmt.Errorf("field %s has unsupported type %s", sf.Name, sf.Type)
+		}
+
+		f := &structField{
+			index: fieldIndex,
+			key:   key,
+			typ:   sf.Type,
+			env:   sf.Tag.Get(EnvTagName),
+		}
+		if defaultValue, ok := sf.Tag.Lookup(DefaultTagName); ok {
+			f.defaultValue = &defaultValue
+		}
+		if validate := sf.Tag.Get(ValidateTagName); validate != "" {
+			rules, err := parseValidationRules(validate, sf.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+			}
+			f.validate = validate
+			f.rules = rules
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+func isSupportedType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return typ.Elem().Kind() == reflect.String || typ.Elem().Kind() == reflect.Int
+	case reflect.Map:
+		return typ.Key().Kind() == reflect.String && typ.Elem().Kind() == reflect.String
+	default:
+		return false
+	}
+}
+
+// decodeValue converts a raw viper value into the given field value.
+func decodeValue(raw interface{}, field reflect.Value) error {
+	if raw == nil {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		s, err := cast.ToStringE(raw)
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := cast.ToBoolE(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		var err error
+		if field.Type() == durationType {
+			var d time.Duration
+			d, err = cast.ToDurationE(raw)
+			i = int64(d)
+		} else {
+			i, err = cast.ToInt64E(raw)
+		}
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, field.Type())
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := cast.ToUint64E(raw)
+		if err != nil {
+			return err
+		}
+		if field.OverflowUint(u) {
+			return fmt.Errorf("value %d overflows %s", u, field.Type())
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := cast.ToFloat64E(raw)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		// Env vars and defaults come as comma separated strings.
+		if s, ok := raw.(string); ok {
+			raw = splitList(s)
+		}
+		var slice interface{}
+		var err error
+		if field.Type().Elem().Kind() == reflect.Int {
+			slice, err = cast.ToIntSliceE(raw)
+		} else {
+			slice, err = cast.ToStringSliceE(raw)
+		}
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(slice).Convert(field.Type()))
+	case reflect.Map:
+		m, err := cast.ToStringMapStringE(raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(m).Convert(field.Type()))
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return []string{}
+	}
+
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts
+}
+
+// parseValidationRules parses rules in the form of "required,min=1,max=10,oneof=a b c".
+func parseValidationRules(validate string, typ reflect.Type) ([]validationRule, error) {
+	var rules []validationRule
+
+	for _, part := range strings.Split(validate, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "required":
+			rules = append(rules, func(value reflect.Value) error {
+				if value.IsZero() {
+					return errors.New("is required")
+				}
+				return nil
+			})
+		case "min", "max":
+			limit, err := parseLimit(arg, typ)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule: %w", name, err)
+			}
+			rules = append(rules, newLimitRule(name, arg, limit))
+		case "oneof":
+			options := strings.Fields(arg)
+			if len(options) == 0 {
+				return nil, errors.New("oneof rule requires at least one option")
+			}
+			rules = append(rules, func(value reflect.Value) error {
+				s := fmt.Sprint(value.Interface())
+				for _, o := range options {
+					if s == o {
+						return nil
+					}
+				}
+				return fmt.Errorf("must be one of %v, got %q", options, s)
+			})
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+	}
+
+	return rules, nil
+}
+
+// parseLimit parses a min/max argument, durations accept values like "1s".
+func parseLimit(arg string, typ reflect.Type) (float64, error) {
+	if typ == durationType {
+		d, err := cast.ToDurationE(arg)
+		return float64(d), err
+	}
+
+	return strconv.ParseFloat(arg, 64)
+}
+
+// newLimitRule checks numbers by value, and strings, slices and maps by length.
+func newLimitRule(name, arg string, limit float64) validationRule {
+	return func(value reflect.Value) error {
+		var actual float64
+		what := "value"
+
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = float64(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = float64(value.Uint())
+		case reflect.Float32, reflect.Float64:
+			actual = value.Float()
+		case reflect.String, reflect.Slice, reflect.Map:
+			actual = float64(value.Len())
+			what = "length"
+		default:
+			return nil
+		}
+
+		if name == "min" && actual < limit {
+			return fmt.Errorf("%s must be at least %s, got %v", what, arg, displayValue(value, what))
+		}
+		if name == "max" && actual > limit {
+			return fmt.Errorf("%s must be at most %s, got %v", what, arg, displayValue(value, what))
+		}
+
+		return nil
+	}
+}
+
+func displayValue(value reflect.Value, what string) interface{} {
+	if what == "length" {
+		return value.Len()
+	}
+
+	return value.Interface()
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/viper"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect emitted by JSONSchema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// JSONSchema returns the JSON Schema of the config file expected by all configs in given registry.
+//
+// Struct configs are described from their tags. Other configs are described from the value their getter
+// returns with defaults only, assuming snake-cased keys.
+func JSONSchema(reg *registry.Registry) map[string]interface{} {
+	// Env vars are not bound, so that the defaults are the ones of the tags rather than of the current env
+	v := viper.New()
+	setDefaultsWithoutEnv(v, reg)
+
+	properties := map[string]interface{}{}
+	reg.IterateConfigs(func(name string, config registry.Config) bool {
+		defaults := config.Get(v)
+		if describer, ok := config.(registry.FieldDescriber); ok {
+			properties[name] = describedSchema(name, describer.Fields(), reflect.ValueOf(defaults))
+		} else {
+			properties[name] = valueSchema(reflect.ValueOf(defaults))
+		}
+		return true
+	})
+
+	return map[string]interface{}{
+		"$schema":    JSONSchemaDraft,
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// describedSchema builds the object schema of a struct config from its field descriptions.
+func describedSchema(name string, fields []registry.FieldDescription, defaults reflect.Value) map[string]interface{} {
+	root := objectSchema()
+	defaults = reflect.Indirect(defaults)
+
+	for _, f := range fields {
+		path := strings.Split(strings.TrimPrefix(f.Key, name+"."), ".")
+
+		parent := root
+		for _, part := range path[:len(path)-1] {
+			properties := parent["properties"].(map[string]interface{})
+			child, ok := properties[part].(map[string]interface{})
+			if !ok {
+				child = objectSchema()
+				properties[part] = child
+			}
+			parent = child
+		}
+
+		schema := typeSchema(f.Type)
+		if f.Default != nil && defaults.IsValid() {
+			schema["default"] = jsonValue(defaults.FieldByIndex(f.Index))
+		}
+		if f.Env != "" {
+			schema["description"] = "env: " + f.Env
+		}
+
+		leaf := path[len(path)-1]
+		if applyValidation(schema, f.Type, f.Validate) {
+			required, _ := parent["required"].([]string)
+			parent["required"] = append(required, leaf)
+		}
+		parent["properties"].(map[string]interface{})[leaf] = schema
+	}
+
+	return root
+}
+
+// valueSchema builds a schema from a config value, using the value as defaults.
+func valueSchema(value reflect.Value) map[string]interface{} {
+	value = reflect.Indirect(value)
+	if !value.IsValid() {
+		return map[string]interface{}{}
+	}
+
+	if value.Kind() != reflect.Struct {
+		schema := typeSchema(value.Type())
+		schema["default"] = jsonValue(value)
+		return schema
+	}
+
+	schema := objectSchema()
+	properties := schema["properties"].(map[string]interface{})
+	for i := 0; i < value.NumField(); i++ {
+		if !value.Type().Field(i).IsExported() {
+			continue
+		}
+		properties[strcase.ToSnake(value.Type().Field(i).Name)] = valueSchema(value.Field(i))
+	}
+
+	return schema
+}
+
+func objectSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func typeSchema(typ reflect.Type) map[string]interface{} {
+	if typ == durationType {
+		return map[string]interface{}{"type": "string", "format": "duration"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(typ.Elem())}
+	case reflect.Ptr:
+		return typeSchema(typ.Elem())
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// applyValidation translates the validate tag into schema keywords and reports whether the field is required.
+func applyValidation(schema map[string]interface{}, typ reflect.Type, validate string) bool {
+	if validate == "" {
+		return false
+	}
+
+	required := false
+	for _, part := range strings.Split(validate, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			var enum []interface{}
+			for _, o := range strings.Fields(arg) {
+				enum = append(enum, o)
+			}
+			schema["enum"] = enum
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				// e.g. duration limits, which JSON Schema cannot express
+				continue
+			}
+			schema[limitKeyword(name, typ)] = limit
+		}
+	}
+
+	return required
+}
+
+func limitKeyword(name string, typ reflect.Type) string {
+	prefix := "minimum"
+	if name == "max" {
+		prefix = "maximum"
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return strings.Replace(prefix, "imum", "Length", 1)
+	case reflect.Slice:
+		return strings.Replace(prefix, "imum", "Items", 1)
+	case reflect.Map:
+		return strings.Replace(prefix, "imum", "Properties", 1)
+	default:
+		return prefix
+	}
+}
+
+func jsonValue(value reflect.Value) interface{} {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+
+	return value.Interface()
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/phuchnd/eeaao/services/go/common/config/registry"
+	"github.com/spf13/viper"
+)
+
+// SecretRefPrefix is the prefix of config values that reference a secret, in the form of
+// `secret://<scheme>/<path>`, e.g. `secret://env/DB_PASSWORD` or `secret://file//run/secrets/db_password`.
+const SecretRefPrefix = "secret://"
+
+// SecretResolver resolves the secrets of one scheme.
+//
+//go:generate mockery --name=SecretResolver --case=snake --disable-version-string
+type SecretResolver interface {
+	// Resolve returns the secret value stored at given path.
+	Resolve(path string) (string, error)
+}
+
+// SecretFileResolver is implemented by resolvers whose secrets are stored in local files.
+// The files are watched so that rotated secrets are re-resolved.
+type SecretFileResolver interface {
+	SecretResolver
+
+	// SecretFile returns the file storing the secret at given path.
+	SecretFile(path string) string
+}
+
+// fileSecretResolver resolves `secret://file/<path>` from the content of the file at path.
+type fileSecretResolver struct{}
+
+// NewFileSecretResolver returns a resolver reading secrets from files, trailing newlines are trimmed.
+func NewFileSecretResolver() SecretResolver {
+	return &fileSecretResolver{}
+}
+
+func (r *fileSecretResolver) Resolve(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func (r *fileSecretResolver) SecretFile(path string) string {
+	return path
+}
+
+// envSecretResolver resolves `secret://env/<NAME>` from the env var NAME.
+type envSecretResolver struct{}
+
+// NewEnvSecretResolver returns a resolver reading secrets from env vars.
+func NewEnvSecretResolver() SecretResolver {
+	return &envSecretResolver{}
+}
+
+func (r *envSecretResolver) Resolve(path string) (string, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("env var %s is not set", path)
+	}
+
+	return value, nil
+}
+
+// ignoredEnvSecretResolver fails to resolve `secret://env/<NAME>` for providers which do not read env vars.
+type ignoredEnvSecretResolver struct{}
+
+func (r *ignoredEnvSecretResolver) Resolve(path string) (string, error) {
+	return "", fmt.Errorf("env var %s is ignored", path)
+}
+
+// staticSecretResolver resolves secrets from an in-memory map.
+type staticSecretResolver struct {
+	secrets map[string]string
+}
+
+// NewStaticSecretResolver returns a resolver backed by given map, e.g. as a local fake of a vault backend.
+func NewStaticSecretResolver(secrets map[string]string) SecretResolver {
+	return &staticSecretResolver{
+		secrets: secrets,
+	}
+}
+
+func (r *staticSecretResolver) Resolve(path string) (string, error) {
+	value, ok := r.secrets[path]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", path)
+	}
+
+	return value, nil
+}
+
+// secretStore resolves and caches secret references.
+type secretStore struct {
+	mu        sync.Mutex
+	resolvers map[string]SecretResolver
+	cache     map[string]string
+	// unresolved only checks the references, see WithUnresolvedSecrets.
+	unresolved bool
+}
+
+func newSecretStore() *secretStore {
+	return &secretStore{
+		resolvers: map[string]SecretResolver{
+			"file": NewFileSecretResolver(),
+			"env":  NewEnvSecretResolver(),
+		},
+		cache: map[string]string{},
+	}
+}
+
+// resolveAll replaces all secret references in v with their values, and returns the files
+// storing file-based secrets.
+//
+// File-based secrets are always re-read so that rotated secrets are picked up on reload,
+// other secrets are served from the cache.
+func (s *secretStore) resolveAll(v *viper.Viper, reg *registry.Registry) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []string
+	for _, key := range secretCandidateKeys(v, reg) {
+		ref, ok := v.Get(key).(string)
+		if !ok || !strings.HasPrefix(ref, SecretRefPrefix) {
+			continue
+		}
+
+		scheme, path, _ := strings.Cut(strings.TrimPrefix(ref, SecretRefPrefix), "/")
+		resolver, ok := s.resolvers[scheme]
+		if !ok {
+			return files, fmt.Errorf("%s: unknown secret scheme %q", key, scheme)
+		}
+		if s.unresolved {
+			if path == "" {
+				return files, fmt.Errorf("%s: missing secret path in %s", key, ref)
+			}
+			continue
+		}
+
+		fileResolver, isFile := resolver.(SecretFileResolver)
+		if isFile {
+			files = append(files, fileResolver.SecretFile(path))
+		}
+
+		value, cached := s.cache[ref]
+		if !cached || isFile {
+			var err error
+			if value, err = resolver.Resolve(path); err != nil {
+				return files, fmt.Errorf("%s: resolve secret %s: %w", key, ref, err)
+			}
+			s.cache[ref] = value
+		}
+
+		v.Set(key, value)
+	}
+
+	return files, nil
+}
+
+// secretCandidateKeys returns the keys known to v together with the keys of registered configs,
+// the latter are needed for values only set by automatically bound env vars.
+func secretCandidateKeys(v *viper.Viper, reg *registry.Registry) []string {
+	keys := v.AllKeys()
+	known := map[string]bool{}
+	for _, key := range keys {
+		known[key] = true
+	}
+
+	reg.IterateConfigs(func(name string, config registry.Config) bool {
+		if lister, ok := config.(registry.KeyLister); ok {
+			for _, key := range lister.Keys() {
+				if !known[key] {
+					known[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		return true
+	})
+
+	return keys
+}
+
+// WithSecretResolver returns an option that registers a resolver for `secret://<scheme>/...` references.
+//
+// The built-in `file` and `env` schemes can be overridden.
+func WithSecretResolver(scheme string, resolver SecretResolver) ProviderOpt {
+	return func(impl *providerImpl) {
+		impl.secrets.resolvers[scheme] = resolver
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a burst of file events is waited out before the callback is invoked, e.g. an
+// editor truncating then rewriting a file.
+const watchDebounce = 100 * time.Millisecond
+
+// fileWatcher watches a set of files and invokes a callback whenever one of them changes.
+//
+// The parent directories are watched instead of the files themselves in order to pick up
+// atomic saves and symlink swaps (e.g. k8s ConfigMap replacement).
+type fileWatcher struct {
+	watcher  *fsnotify.Watcher
+	onChange func()
+	debounce time.Duration
+
+	mu    sync.Mutex
+	files map[string]string // cleaned file path -> resolved real path
+	dirs  map[string]bool
+}
+
+// newFileWatcher creates a new fileWatcher and starts its event loop.
+func newFileWatcher(onChange func()) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &fileWatcher{
+		watcher:  watcher,
+		onChange: onChange,
+		debounce: watchDebounce,
+		files:    map[string]string{},
+		dirs:     map[string]bool{},
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// add starts watching the given file. Adding a file twice is a no-op.
+func (w *fileWatcher) add(file string) error {
+	file = filepath.Clean(file)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.files[file]; ok {
+		return nil
+	}
+
+	dir := filepath.Dir(file)
+	if !w.dirs[dir] {
+		if err := w.watcher.Add(dir); err != nil {
+			return err
+		}
+		w.dirs[dir] = true
+	}
+
+	realFile, _ := filepath.EvalSymlinks(file)
+	w.files[file] = realFile
+
+	return nil
+}
+
+// close stops the watcher.
+func (w *fileWatcher) close() error {
+	return w.watcher.Close()
+}
+
+func (w *fileWatcher) run() {
+	// The callback is invoked once the events stop for the debounce duration
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.isChanged(event) {
+				timer.Reset(w.debounce)
+			}
+		case <-timer.C:
+			w.onChange()
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// isChanged reports whether the event affects one of the watched files. We care about:
+// 1 - a watched file was modified, created or removed
+// 2 - the real path of a watched file changed
+func (w *fileWatcher) isChanged(event fsnotify.Event) bool {
+	name := filepath.Clean(event.Name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := false
+	for file, realFile := range w.files {
+		currentRealFile, _ := filepath.EvalSymlinks(file)
+		if (name == file && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove))) ||
+			(currentRealFile != "" && currentRealFile != realFile) {
+			w.files[file] = currentRealFile
+			changed = true
+		}
+	}
+
+	return changed
+}
@@ -1,9 +1,10 @@
 package mysql
 
 type Config struct {
-	Host            string
-	Port            int
-	Username        string
+	Host     string
+	Port     int
+	Username string
+	// Password can be a secret reference resolved by the config provider, e.g. `secret://env/DB_PASSWORD`.
 	Password        string
 	Database        string
 	MaxIdleConns    int
@@ -0,0 +1,156 @@
+package errors
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code classifies an Error, it is carried as is in gRPC statuses and HTTP error bodies.
+type Code string
+
+const (
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeInvalidArgument    Code = "INVALID_ARGUMENT"
+	CodeUnimplemented      Code = "UNIMPLEMENTED"
+	CodeUnauthorized       Code = "UNAUTHENTICATED"
+	CodePermissionDenied   Code = "PERMISSION_DENIED"
+	CodeAlreadyExists      Code = "ALREADY_EXISTS"
+	CodeFailedPrecondition Code = "FAILED_PRECONDITION"
+	CodeResourceExhausted  Code = "RESOURCE_EXHAUSTED"
+	CodeUnavailable        Code = "UNAVAILABLE"
+	CodeUnknown            Code = "UNKNOWN"
+	CodeInternal           Code = "INTERNAL"
+	CodeUnsupported        Code = "UNSUPPORTED"
+	CodeTimeOut            Code = "DEADLINE_EXCEEDED"
+	CodeCanceled           Code = "CANCELED"
+	CodeAborted            Code = "ABORTED"
+	CodeOutOfRange         Code = "OUT_OF_RANGE"
+	CodeDataLoss           Code = "DATA_LOSS"
+)
+
+// StatusClientClosedRequest is the non-standard HTTP status of canceled calls, as used by nginx.
+const StatusClientClosedRequest = 499
+
+// codeMapping maps a code to its sentinel error and to its gRPC and HTTP statuses.
+type codeMapping struct {
+	sentinel   error
+	grpcCode   codes.Code
+	httpStatus int
+}
+
+var codeMappings = map[Code]codeMapping{
+	CodeNotFound:           {ErrNotFound, codes.NotFound, http.StatusNotFound},
+	CodeInvalidArgument:    {ErrInvalidArgument, codes.InvalidArgument, http.StatusBadRequest},
+	CodeUnimplemented:      {ErrUnimplemented, codes.Unimplemented, http.StatusNotImplemented},
+	CodeUnauthorized:       {ErrUnauthorized, codes.Unauthenticated, http.StatusUnauthorized},
+	CodePermissionDenied:   {ErrPermissionDenied, codes.PermissionDenied, http.StatusForbidden},
+	CodeAlreadyExists:      {ErrAlreadyExists, codes.AlreadyExists, http.StatusConflict},
+	CodeFailedPrecondition: {ErrFailedPrecondition, codes.FailedPrecondition, http.StatusPreconditionFailed},
+	CodeResourceExhausted:  {ErrResourceExhausted, codes.ResourceExhausted, http.StatusTooManyRequests},
+	CodeUnavailable:        {ErrUnavailable, codes.Unavailable, http.StatusServiceUnavailable},
+	CodeUnknown:            {ErrUnknown, codes.Unknown, http.StatusInternalServerError},
+	CodeInternal:           {ErrInternal, codes.Internal, http.StatusInternalServerError},
+	CodeUnsupported:        {ErrUnsupported, codes.Unimplemented, http.StatusUnsupportedMediaType},
+	CodeTimeOut:            {ErrTimeOut, codes.DeadlineExceeded, http.StatusGatewayTimeout},
+	CodeCanceled:           {ErrCanceled, codes.Canceled, StatusClientClosedRequest},
+	CodeAborted:            {ErrAborted, codes.Aborted, http.StatusConflict},
+	CodeOutOfRange:         {ErrOutOfRange, codes.OutOfRange, http.StatusBadRequest},
+	CodeDataLoss:           {ErrDataLoss, codes.DataLoss, http.StatusInternalServerError},
+}
+
+// sentinelCodes are the codes matched in order against the sentinel errors, more specific ones first.
+var sentinelCodes = []Code{
+	CodeCanceled, CodeNotFound, CodeInvalidArgument, CodeUnimplemented, CodeUnauthorized, CodePermissionDenied, CodeAlreadyExists,
+	CodeFailedPrecondition, CodeAborted, CodeOutOfRange, CodeResourceExhausted, CodeUnavailable, CodeTimeOut,
+	CodeUnsupported, CodeDataLoss, CodeInternal, CodeUnknown,
+}
+
+// Codes of the gRPC and HTTP statuses received without an explicit code.
+var (
+	grpcCodes = map[codes.Code]Code{
+		codes.Canceled:           CodeCanceled,
+		codes.Unknown:            CodeUnknown,
+		codes.InvalidArgument:    CodeInvalidArgument,
+		codes.DeadlineExceeded:   CodeTimeOut,
+		codes.NotFound:           CodeNotFound,
+		codes.AlreadyExists:      CodeAlreadyExists,
+		codes.PermissionDenied:   CodePermissionDenied,
+		codes.ResourceExhausted:  CodeResourceExhausted,
+		codes.FailedPrecondition: CodeFailedPrecondition,
+		codes.Aborted:            CodeAborted,
+		codes.OutOfRange:         CodeOutOfRange,
+		codes.Unimplemented:      CodeUnimplemented,
+		codes.Internal:           CodeInternal,
+		codes.Unavailable:        CodeUnavailable,
+		codes.DataLoss:           CodeDataLoss,
+		codes.Unauthenticated:    CodeUnauthorized,
+	}
+	httpCodes = map[int]Code{
+		http.StatusBadRequest:           CodeInvalidArgument,
+		http.StatusUnauthorized:         CodeUnauthorized,
+		http.StatusForbidden:            CodePermissionDenied,
+		http.StatusNotFound:             CodeNotFound,
+		http.StatusMethodNotAllowed:     CodeUnimplemented,
+		http.StatusRequestTimeout:       CodeTimeOut,
+		http.StatusConflict:             CodeAlreadyExists,
+		http.StatusPreconditionFailed:   CodeFailedPrecondition,
+		http.StatusUnsupportedMediaType: CodeUnsupported,
+		http.StatusUnprocessableEntity:  CodeInvalidArgument,
+		StatusClientClosedRequest:       CodeCanceled,
+		http.StatusTooManyRequests:      CodeResourceExhausted,
+		http.StatusInternalServerError:  CodeInternal,
+		http.StatusNotImplemented:       CodeUnimplemented,
+		http.StatusBadGateway:           CodeUnavailable,
+		http.StatusServiceUnavailable:   CodeUnavailable,
+		http.StatusGatewayTimeout:       CodeTimeOut,
+	}
+)
+
+// GRPCCode returns the gRPC code of the code, Unknown for unknown codes.
+func (c Code) GRPCCode() codes.Code {
+	if m, ok := codeMappings[c]; ok {
+		return m.grpcCode
+	}
+	return codes.Unknown
+}
+
+// HTTPStatus returns the HTTP status of the code, 500 for unknown codes.
+func (c Code) HTTPStatus() int {
+	if m, ok := codeMappings[c]; ok {
+		return m.httpStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// sentinel returns the sentinel error of the code, ErrUnknown for unknown codes.
+func (c Code) sentinel() error {
+	if m, ok := codeMappings[c]; ok {
+		return m.sentinel
+	}
+	return ErrUnknown
+}
+
+// CodeFromGRPC returns the code of a gRPC code.
+func CodeFromGRPC(code codes.Code) Code {
+	if c, ok := grpcCodes[code]; ok {
+		return c
+	}
+	return CodeUnknown
+}
+
+// CodeFromHTTPStatus returns the code of an HTTP error status, other 4xx are invalid arguments and other 5xx
+// are internal errors.
+func CodeFromHTTPStatus(status int) Code {
+	if c, ok := httpCodes[status]; ok {
+		return c
+	}
+	switch {
+	case status >= 400 && status < 500:
+		return CodeInvalidArgument
+	case status >= 500:
+		return CodeInternal
+	default:
+		return CodeUnknown
+	}
+}
@@ -0,0 +1,228 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the gRPC ErrorInfo details carrying the error codes.
+const ErrorDomain = "eeaao"
+
+// FieldViolation describes an invalid field of a request.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Error is a domain error, convertible from and to gRPC statuses and HTTP responses.
+//
+// It matches the sentinel error of its code and its cause with errors.Is, e.g. an Error with
+// CodeNotFound is ErrNotFound.
+type Error struct {
+	Code Code
+	// Message is safe to return to users, unlike the cause.
+	Message         string
+	Cause           error
+	FieldViolations []FieldViolation
+}
+
+// New returns a new Error with given code and user-safe message.
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap returns a new Error with given internal cause.
+func Wrap(cause error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Cause: cause}
+}
+
+// WithFieldViolation adds a field violation, it returns the error for chaining.
+func (e *Error) WithFieldViolation(field, description string) *Error {
+	e.FieldViolations = append(e.FieldViolations, FieldViolation{Field: field, Description: description})
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Code, e.message())
+	for _, v := range e.FieldViolations {
+		msg += fmt.Sprintf(", %s: %s", v.Field, v.Description)
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error of the code and the cause, if any.
+func (e *Error) Unwrap() []error {
+	if e.Cause != nil {
+		return []error{e.Code.sentinel(), e.Cause}
+	}
+	return []error{e.Code.sentinel()}
+}
+
+// message returns the user-safe message, defaulting to the sentinel one.
+func (e *Error) message() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Code.sentinel().Error()
+}
+
+// GRPCStatus converts the error into a gRPC status, the code is carried in an ErrorInfo detail and the
+// field violations in a BadRequest detail. The cause is not sent, unless it is a gRPC status of the same
+// code which is returned unchanged, e.g. to keep the RetryInfo details of errors received from another service.
+//
+// gRPC servers use it to send the error as is.
+func (e *Error) GRPCStatus() *status.Status {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if e.Cause != nil && errors.As(e.Cause, &grpcErr) {
+		if st := grpcErr.GRPCStatus(); st.Code() == e.Code.GRPCCode() {
+			return st
+		}
+	}
+
+	st := status.New(e.Code.GRPCCode(), e.message())
+
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(e.Code), Domain: ErrorDomain})
+	if err != nil {
+		return st
+	}
+	if len(e.FieldViolations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range e.FieldViolations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		if withViolations, err := withDetails.WithDetails(badRequest); err == nil {
+			withDetails = withViolations
+		}
+	}
+
+	return withDetails
+}
+
+// HTTPStatus returns the HTTP status of the error.
+func (e *Error) HTTPStatus() int {
+	return e.Code.HTTPStatus()
+}
+
+// HTTPBody is the JSON body of HTTP error responses.
+type HTTPBody struct {
+	Code            Code             `json:"code,omitempty"`
+	Message         string           `json:"message"`
+	Err             string           `json:"error,omitempty"`
+	FieldViolations []FieldViolation `json:"field_violations,omitempty"`
+}
+
+// HTTPBody returns the JSON body of the error. The cause is not sent.
+func (e *Error) HTTPBody() HTTPBody {
+	return HTTPBody{
+		Code:            e.Code,
+		Message:         e.message(),
+		FieldViolations: e.FieldViolations,
+	}
+}
+
+// WriteHTTP writes an error as a JSON HTTP response, errors other than Error are converted by FromError.
+func WriteHTTP(w http.ResponseWriter, err error) {
+	e := FromError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.HTTPStatus())
+	_ = json.NewEncoder(w).Encode(e.HTTPBody())
+}
+
+// FromError converts any error into an Error:
+//   - an Error found in the chain is returned as is
+//   - gRPC status errors are converted by FromGRPCStatus
+//   - context errors and sentinel errors get their code
+//   - other errors are unknown
+//
+// Nil errors return nil.
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if st, ok := status.FromError(err); ok {
+		return FromGRPCStatus(st)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Wrap(err, CodeTimeOut, "")
+	}
+	for _, code := range sentinelCodes {
+		if errors.Is(err, code.sentinel()) {
+			return Wrap(err, code, "")
+		}
+	}
+
+	return Wrap(err, CodeUnknown, "")
+}
+
+// FromGRPCStatus converts a gRPC status into an Error, using the ErrorInfo and BadRequest details if any.
+// Nil and OK statuses return nil.
+func FromGRPCStatus(st *status.Status) *Error {
+	if st == nil || st.Err() == nil {
+		return nil
+	}
+
+	e := &Error{
+		Code:    CodeFromGRPC(st.Code()),
+		Message: st.Message(),
+		Cause:   st.Err(),
+	}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() == ErrorDomain {
+				if _, ok := codeMappings[Code(d.GetReason())]; ok {
+					e.Code = Code(d.GetReason())
+				}
+			}
+		case *errdetails.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				e.WithFieldViolation(v.GetField(), v.GetDescription())
+			}
+		}
+	}
+
+	return e
+}
+
+// FromHTTPResponse converts an HTTP error response into an Error, using its JSON body if any.
+func FromHTTPResponse(statusCode int, body []byte) *Error {
+	e := &Error{Code: CodeFromHTTPStatus(statusCode)}
+
+	var httpBody HTTPBody
+	if err := json.Unmarshal(body, &httpBody); err != nil {
+		e.Message = http.StatusText(statusCode)
+		return e
+	}
+
+	if _, ok := codeMappings[httpBody.Code]; ok {
+		e.Code = httpBody.Code
+	}
+	e.Message = httpBody.Message
+	if e.Message == "" {
+		e.Message = httpBody.Err
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(statusCode)
+	}
+	e.FieldViolations = httpBody.FieldViolations
+
+	return e
+}
@@ -1,17 +1,27 @@
 package errors
 
 import (
+	"context"
 	"errors"
 )
 
 var (
-	ErrNotFound        = errors.New("not found")
-	ErrInvalidArgument = errors.New("invalid argument")
-	ErrUnimplemented   = errors.New("unimplemented")
-	ErrUnauthorized    = errors.New("unauthorized")
-	ErrUnavailable     = errors.New("service unavailable")
-	ErrUnknown         = errors.New("unknown")
-	ErrInternal        = errors.New("internal")
-	ErrUnsupported     = errors.New("unsupported")
-	ErrTimeOut         = errors.New("request timeout")
+	ErrNotFound           = errors.New("not found")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrUnimplemented      = errors.New("unimplemented")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrFailedPrecondition = errors.New("failed precondition")
+	ErrResourceExhausted  = errors.New("resource exhausted")
+	ErrUnavailable        = errors.New("service unavailable")
+	ErrUnknown            = errors.New("unknown")
+	ErrInternal           = errors.New("internal")
+	ErrUnsupported        = errors.New("unsupported")
+	ErrTimeOut            = errors.New("request timeout")
+	ErrAborted            = errors.New("aborted")
+	ErrOutOfRange         = errors.New("out of range")
+	ErrDataLoss           = errors.New("data loss")
+	// ErrCanceled is context.Canceled, so that canceled calls match it whether they are converted or not.
+	ErrCanceled = context.Canceled
 )
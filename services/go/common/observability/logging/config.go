@@ -3,15 +3,26 @@ package logging
 import (
 	"github.com/phuchnd/eeaao/services/go/common/config"
 	"github.com/phuchnd/eeaao/services/go/common/config/registry"
-	"github.com/spf13/viper"
 )
 
 // ConfigName is the logging configuration name
 const ConfigName = "logging"
 
 type Config struct {
-	IsDevelopment bool
-	Level         string
+	// IsDevelopment switches from the JSON encoder to the human-friendly console encoder.
+	IsDevelopment bool   `config:"is_development" default:"true"`
+	Level         string `config:"level" default:"debug" validate:"oneof=debug info warn error dpanic panic fatal"`
+	DisableCaller bool   `config:"disable_caller"`
+	// SamplingInitial and SamplingThereafter cap the entries with the same level and message per second:
+	// the first SamplingInitial are logged, then every SamplingThereafter-th. 0 disables sampling.
+	SamplingInitial    int `config:"sampling_initial" default:"100" validate:"min=0"`
+	SamplingThereafter int `config:"sampling_thereafter" default:"100" validate:"min=0"`
+	// RedactFields are the field names whose values are always redacted, matched in snake_case.
+	RedactFields []string `config:"redact_fields" default:"password,secret,token,access_token,refresh_token,id_token,api_key,authorization,cookie,email,phone"`
+	// RedactQueryParams are the query parameters scrubbed from URLs found in log entries.
+	RedactQueryParams []string `config:"redact_query_params" default:"token,access_token,refresh_token,id_token,code,api_key,key,signature,email"`
+	// RedactPatterns are additional regexes whose matches are redacted, on top of emails, JWTs and bearer tokens.
+	RedactPatterns []string `config:"redact_patterns"`
 }
 
 func GetConfig(cp config.Provider) *Config {
@@ -19,15 +30,5 @@ func GetConfig(cp config.Provider) *Config {
 }
 
 func init() {
-	registry.RegisterConfig(ConfigName, registry.NewConfig(func(v *viper.Viper) interface{} {
-		return &Config{
-			IsDevelopment: v.GetBool(ConfigName + ".is_development"),
-			Level:         v.GetString(ConfigName + ".level"),
-		}
-	}, registry.WithSetDefault(func(v *viper.Viper) {
-		v.SetDefault(ConfigName, map[string]interface{}{
-			"is_development": true,
-			"level":          "debug",
-		})
-	})))
+	registry.RegisterConfig(ConfigName, registry.NewStructConfig[Config](ConfigName))
 }
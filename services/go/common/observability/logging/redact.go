@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactedValue replaces redacted values in log entries.
+const RedactedValue = "[REDACTED]"
+
+var defaultRedactPatterns = []*regexp.Regexp{
+	// emails
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// JWTs
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+}
+
+// bearerPattern matches bearer tokens, keeping the scheme.
+var bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+
+// Redactor scrubs sensitive data from log messages and fields.
+type Redactor struct {
+	fields      map[string]bool
+	patterns    []*regexp.Regexp
+	queryParams *regexp.Regexp
+	jsonFields  *regexp.Regexp
+}
+
+// NewRedactor creates a Redactor from the redaction settings of given logging config.
+//
+// Emails, JWTs and bearer tokens are always redacted.
+func NewRedactor(cfg *Config) (*Redactor, error) {
+	r := &Redactor{
+		fields:   map[string]bool{},
+		patterns: append([]*regexp.Regexp(nil), defaultRedactPatterns...),
+	}
+
+	jsonFields := make([]string, 0, len(cfg.RedactFields))
+	for _, f := range cfg.RedactFields {
+		r.fields[normalizeFieldName(f)] = true
+		jsonFields = append(jsonFields, regexp.QuoteMeta(f))
+	}
+	if len(jsonFields) > 0 {
+		// Sensitive fields embedded in JSON strings, e.g. logged response bodies.
+		r.jsonFields = regexp.MustCompile(`(?i)("(?:` + strings.Join(jsonFields, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	}
+
+	for _, p := range cfg.RedactPatterns {
+		pattern, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+
+	if len(cfg.RedactQueryParams) > 0 {
+		params := make([]string, 0, len(cfg.RedactQueryParams))
+		for _, p := range cfg.RedactQueryParams {
+			params = append(params, regexp.QuoteMeta(p))
+		}
+		r.queryParams = regexp.MustCompile(`(?i)([?&](?:` + strings.Join(params, "|") + `)=)[^&\s#"']*`)
+	}
+
+	return r, nil
+}
+
+// RedactString scrubs the sensitive query parameters, JSON fields and patterns from given string.
+func (r *Redactor) RedactString(s string) string {
+	if r.jsonFields != nil {
+		s = r.jsonFields.ReplaceAllString(s, `${1}"`+RedactedValue+`"`)
+	}
+	if r.queryParams != nil {
+		s = r.queryParams.ReplaceAllString(s, "${1}"+RedactedValue)
+	}
+	s = bearerPattern.ReplaceAllString(s, "${1}"+RedactedValue)
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, RedactedValue)
+	}
+
+	return s
+}
+
+// RedactValue scrubs the value of the field with given key.
+func (r *Redactor) RedactValue(key string, value interface{}) interface{} {
+	if r.fields[normalizeFieldName(key)] {
+		return RedactedValue
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.RedactString(v)
+	case []byte:
+		return r.RedactString(string(v))
+	case *url.URL:
+		if v == nil {
+			return v
+		}
+		return r.RedactString(v.String())
+	case error:
+		return r.RedactString(v.Error())
+	case fmt.Stringer:
+		return r.RedactString(v.String())
+	default:
+		return value
+	}
+}
+
+// redactArgs scrubs the args of the non-structured log methods.
+func (r *Redactor) redactArgs(args []interface{}) []interface{} {
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		redacted[i] = r.RedactValue("", arg)
+	}
+
+	return redacted
+}
+
+// redactKeyValues scrubs Key/Value pairs, strongly typed zap fields are supported as well.
+func (r *Redactor) redactKeyValues(args []interface{}) []interface{} {
+	redacted := make([]interface{}, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if field, ok := args[i].(zap.Field); ok {
+			redacted = append(redacted, r.redactField(field))
+			continue
+		}
+
+		key, ok := args[i].(string)
+		if !ok || i == len(args)-1 {
+			// Not a pair, zap reports it as is.
+			redacted = append(redacted, r.RedactValue("", args[i]))
+			continue
+		}
+
+		redacted = append(redacted, key, r.RedactValue(key, args[i+1]))
+		i++
+	}
+
+	return redacted
+}
+
+func (r *Redactor) redactField(field zap.Field) zap.Field {
+	if r.fields[normalizeFieldName(field.Key)] {
+		return zap.String(field.Key, RedactedValue)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		return zap.String(field.Key, r.RedactString(field.String))
+	case zapcore.ErrorType, zapcore.StringerType:
+		return zap.Any(field.Key, r.RedactValue(field.Key, field.Interface))
+	default:
+		return field
+	}
+}
+
+func normalizeFieldName(name string) string {
+	return strcase.ToSnake(name)
+}
+
+// redactingLogger scrubs sensitive data before delegating to another Logger.
+type redactingLogger struct {
+	next     Logger
+	redactor *Redactor
+}
+
+// NewRedactingLogger wraps any Logger so that sensitive data never reaches its sinks.
+//
+// The wrapper adds one call frame, zap loggers should skip it with zap.AddCallerSkip(1).
+func NewRedactingLogger(next Logger, redactor *Redactor) Logger {
+	return &redactingLogger{
+		next:     next,
+		redactor: redactor,
+	}
+}
+
+func (l *redactingLogger) Error(args ...interface{}) {
+	l.next.Error(l.redactor.redactArgs(args)...)
+}
+
+func (l *redactingLogger) Warn(args ...interface{}) {
+	l.next.Warn(l.redactor.redactArgs(args)...)
+}
+
+func (l *redactingLogger) Info(args ...interface{}) {
+	l.next.Info(l.redactor.redactArgs(args)...)
+}
+
+func (l *redactingLogger) Debug(args ...interface{}) {
+	l.next.Debug(l.redactor.redactArgs(args)...)
+}
+
+func (l *redactingLogger) Errorw(msg string, args ...interface{}) {
+	l.next.Errorw(l.redactor.RedactString(msg), l.redactor.redactKeyValues(args)...)
+}
+
+func (l *redactingLogger) Warnw(msg string, args ...interface{}) {
+	l.next.Warnw(l.redactor.RedactString(msg), l.redactor.redactKeyValues(args)...)
+}
+
+func (l *redactingLogger) Infow(msg string, args ...interface{}) {
+	l.next.Infow(l.redactor.RedactString(msg), l.redactor.redactKeyValues(args)...)
+}
+
+func (l *redactingLogger) Debugw(msg string, args ...interface{}) {
+	l.next.Debugw(l.redactor.RedactString(msg), l.redactor.redactKeyValues(args)...)
+}
+
+func (l *redactingLogger) With(args ...interface{}) Logger {
+	return &redactingLogger{
+		next:     l.next.With(l.redactor.redactKeyValues(args)...),
+		redactor: l.redactor,
+	}
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"fmt"
+
+	"github.com/phuchnd/eeaao/services/go/common/config"
+	"go.uber.org/zap"
+)
+
+// Option is an option on a Logger created by NewLogger.
+type Option func(o *loggerOptions)
+
+type loggerOptions struct {
+	level   *zap.AtomicLevel
+	zapOpts []zap.Option
+}
+
+// zapLogger is a Logger backed by zap.
+type zapLogger struct {
+	*zap.SugaredLogger
+}
+
+// NewLogger creates a zap-backed Logger configured from given logging config, sensitive data is redacted
+// as configured.
+//
+// The level can be changed at runtime through an atomic level given by WithAtomicLevel,
+// which also serves as an HTTP handler (GET to read, PUT {"level":"info"} to change).
+func NewLogger(cfg *Config, opts ...Option) (Logger, error) {
+	o := &loggerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	level := zap.NewAtomicLevel()
+	if o.level != nil {
+		level = *o.level
+	}
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	zapCfg := zap.NewProductionConfig()
+	if cfg.IsDevelopment {
+		zapCfg = zap.NewDevelopmentConfig()
+	}
+	zapCfg.Level = level
+	zapCfg.DisableCaller = cfg.DisableCaller
+	zapCfg.Sampling = nil
+	if cfg.SamplingInitial > 0 {
+		zapCfg.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.SamplingInitial,
+			Thereafter: cfg.SamplingThereafter,
+		}
+	}
+
+	redactor, err := NewRedactor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the redacting wrapper frame when reporting the caller.
+	logger, err := zapCfg.Build(append([]zap.Option{zap.AddCallerSkip(1)}, o.zapOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedactingLogger(&zapLogger{logger.Sugar()}, redactor), nil
+}
+
+// With returns a new Logger with given args as default Key/Value pairs.
+func (l *zapLogger) With(args ...interface{}) Logger {
+	return &zapLogger{
+		l.SugaredLogger.With(args...),
+	}
+}
+
+// WatchLevel keeps given atomic level in sync with the hot-reloaded logging config.
+func WatchLevel(cp config.Provider, level zap.AtomicLevel) {
+	cp.Subscribe(ConfigName, func(_, newValue interface{}) {
+		cfg, ok := newValue.(*Config)
+		if !ok {
+			return
+		}
+		_ = level.UnmarshalText([]byte(cfg.Level))
+	})
+}
+
+// WithAtomicLevel returns an option that makes the Logger use given level, so it can be changed at runtime.
+func WithAtomicLevel(level zap.AtomicLevel) Option {
+	return func(o *loggerOptions) {
+		o.level = &level
+	}
+}
+
+// WithZapOptions returns an option that passes given options to the underlying zap logger, e.g. hooks.
+func WithZapOptions(zapOpts ...zap.Option) Option {
+	return func(o *loggerOptions) {
+		o.zapOpts = append(o.zapOpts, zapOpts...)
+	}
+}
@@ -5,19 +5,53 @@ import (
 	"time"
 )
 
+// Labels are the Key/Value dimensions of a metric. A metric must always be recorded with the same label names.
+type Labels map[string]string
+
 //go:generate mockery --name=Metrics --case=snake --disable-version-string
 type Metrics interface {
 	SendExternalServiceMetric(ctx context.Context, start time.Time, serviceName, externalServiceName, reqURL, reqMethod, respStatus string)
-}
 
-type metricsImpl struct{}
+	// IncCounter adds 1 to a counter, e.g. the number of quests started.
+	IncCounter(name string, labels Labels)
+	// AddCounter adds a non-negative value to a counter.
+	AddCounter(name string, value float64, labels Labels)
+	// SetGauge sets the value of a gauge, e.g. the number of active campaigns.
+	SetGauge(name string, value float64, labels Labels)
+	// AddGauge adds a possibly negative value to a gauge.
+	AddGauge(name string, value float64, labels Labels)
+	// ObserveHistogram records a value in a histogram, e.g. the number of steps per ingestion.
+	ObserveHistogram(name string, value float64, labels Labels)
+	// StartTimer starts timing an operation, the returned function records the elapsed seconds in a histogram.
+	StartTimer(name string, labels Labels) func()
+}
 
+// NewMetrics returns a Prometheus-backed Metrics, see NewPrometheusMetrics.
 func NewMetrics() Metrics {
-	return &metricsImpl{}
+	return NewPrometheusMetrics()
 }
 
-func (m *metricsImpl) SendExternalServiceMetric(ctx context.Context, start time.Time, serviceName, externalServiceName, reqURL, reqMethod, respStatus string) {
-	//elapsed := time.Since(start)
-	//msec := float64(elapsed.Nanoseconds()) / float64(time.Millisecond)
-	//
+// nopMetrics records nothing.
+type nopMetrics struct{}
+
+// NewNopMetrics returns a Metrics recording nothing.
+func NewNopMetrics() Metrics {
+	return &nopMetrics{}
+}
+
+func (m *nopMetrics) SendExternalServiceMetric(context.Context, time.Time, string, string, string, string, string) {
+}
+
+func (m *nopMetrics) IncCounter(string, Labels) {}
+
+func (m *nopMetrics) AddCounter(string, float64, Labels) {}
+
+func (m *nopMetrics) SetGauge(string, float64, Labels) {}
+
+func (m *nopMetrics) AddGauge(string, float64, Labels) {}
+
+func (m *nopMetrics) ObserveHistogram(string, float64, Labels) {}
+
+func (m *nopMetrics) StartTimer(string, Labels) func() {
+	return func() {}
 }
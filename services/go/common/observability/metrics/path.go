@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Placeholders of the path segments replaced by TemplatePath.
+const (
+	PathPlaceholderID   = ":id"
+	PathPlaceholderUUID = ":uuid"
+	PathPlaceholderHex  = ":hex"
+)
+
+var (
+	numericSegmentPattern = regexp.MustCompile(`^[0-9]+$`)
+	uuidSegmentPattern    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	// hexSegmentPattern matches hex IDs like Mongo object IDs or hashes, short words like "cafe" are kept.
+	hexSegmentPattern = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// TemplatePath replaces the ID segments of a URL path, so that metrics labeled by path keep a bounded
+// cardinality, e.g. `/users/42/quests/5f0c...` becomes `/users/:id/quests/:hex`.
+// Full URLs are accepted, their scheme, host and query are dropped.
+func TemplatePath(reqURL string) string {
+	path := reqURL
+	if u, err := url.Parse(reqURL); err == nil {
+		path = u.Path
+	}
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+		case numericSegmentPattern.MatchString(segment):
+			segments[i] = PathPlaceholderID
+		case uuidSegmentPattern.MatchString(segment):
+			segments[i] = PathPlaceholderUUID
+		case hexSegmentPattern.MatchString(segment):
+			segments[i] = PathPlaceholderHex
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
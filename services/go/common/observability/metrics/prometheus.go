@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Labels of the external service metrics.
+const (
+	LabelService         = "service"
+	LabelExternalService = "external_service"
+	LabelMethod          = "method"
+	LabelPath            = "path"
+	LabelStatus          = "status"
+)
+
+// Names of the external service metrics, prefixed by the namespace if any.
+const (
+	ExternalServiceDurationMetricName = "external_service_request_duration_seconds"
+	ExternalServiceRequestsMetricName = "external_service_requests_total"
+)
+
+var externalServiceLabels = []string{LabelService, LabelExternalService, LabelMethod, LabelPath, LabelStatus}
+
+// PrometheusMetrics is a Metrics recorded in a Prometheus registry.
+type PrometheusMetrics interface {
+	Metrics
+	// Handler serves the recorded metrics in the Prometheus text format, e.g. on `/metrics`.
+	Handler() http.Handler
+}
+
+type prometheusOptions struct {
+	registry     *prometheus.Registry
+	namespace    string
+	buckets      []float64
+	pathTemplate func(reqURL string) string
+	errorHandler func(err error)
+	collectors   []prometheus.Collector
+}
+
+// PrometheusOpt is an option on Prometheus-backed metrics.
+type PrometheusOpt func(o *prometheusOptions)
+
+// WithPrometheusRegistry returns an option that allows setting of the registry the metrics are recorded
+// in and served from. A new registry is used by default.
+func WithPrometheusRegistry(registry *prometheus.Registry) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.registry = registry
+	}
+}
+
+// WithNamespace returns an option that allows prefixing the metric names, e.g. by the service name.
+func WithNamespace(namespace string) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithLatencyBuckets returns an option that allows setting of the histogram buckets, the Prometheus
+// default buckets suit latencies in seconds.
+func WithLatencyBuckets(buckets []float64) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.buckets = buckets
+	}
+}
+
+// WithPathTemplate returns an option that allows setting of the function turning request URLs into
+// path labels, TemplatePath by default.
+func WithPathTemplate(pathTemplate func(reqURL string) string) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.pathTemplate = pathTemplate
+	}
+}
+
+// WithMetricsErrorHandler returns an option that allows handling of errors raised while recording,
+// e.g. a metric recorded with different label names. Such errors are dropped by default.
+func WithMetricsErrorHandler(handler func(err error)) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// WithRuntimeCollector returns an option that allows collecting the Go runtime stats, e.g. goroutines,
+// GC pauses and heap, and the process stats, e.g. CPU time and open file descriptors.
+func WithRuntimeCollector() PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.collectors = append(o.collectors,
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+}
+
+// WithDBStatsCollector returns an option that allows collecting the connection pool stats of a database,
+// e.g. open, in-use and idle connections, wait count and wait duration, labeled by given name.
+//
+// The pool of a mysql.IMySqlDB is the one of its gorm DB:
+//
+//	sqlDB, err := db.DB().DB()
+//	...
+//	m := metrics.NewPrometheusMetrics(metrics.WithDBStatsCollector("main", sqlDB))
+func WithDBStatsCollector(dbName string, db *sql.DB) PrometheusOpt {
+	return func(o *prometheusOptions) {
+		o.collectors = append(o.collectors, collectors.NewDBStatsCollector(db, dbName))
+	}
+}
+
+type prometheusMetricsImpl struct {
+	registry     *prometheus.Registry
+	namespace    string
+	buckets      []float64
+	pathTemplate func(reqURL string) string
+	errorHandler func(err error)
+
+	mu         sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	gauges     map[string]*prometheus.GaugeVec
+	histograms map[string]*prometheus.HistogramVec
+
+	externalDuration *prometheus.HistogramVec
+	externalRequests *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics returns metrics recorded in a Prometheus registry.
+//
+// It panics if the metrics or collectors are already registered in the given registry.
+func NewPrometheusMetrics(opts ...PrometheusOpt) PrometheusMetrics {
+	o := &prometheusOptions{
+		buckets:      prometheus.DefBuckets,
+		pathTemplate: TemplatePath,
+		errorHandler: func(error) {},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.registry == nil {
+		o.registry = prometheus.NewRegistry()
+	}
+
+	m := &prometheusMetricsImpl{
+		registry:     o.registry,
+		namespace:    o.namespace,
+		buckets:      o.buckets,
+		pathTemplate: o.pathTemplate,
+		errorHandler: o.errorHandler,
+		counters:     map[string]*prometheus.CounterVec{},
+		gauges:       map[string]*prometheus.GaugeVec{},
+		histograms:   map[string]*prometheus.HistogramVec{},
+		externalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      ExternalServiceDurationMetricName,
+			Help:      "Duration of the calls to external services, retries included.",
+			Buckets:   o.buckets,
+		}, externalServiceLabels),
+		externalRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      ExternalServiceRequestsMetricName,
+			Help:      "Number of calls to external services.",
+		}, externalServiceLabels),
+	}
+	m.registry.MustRegister(m.externalDuration, m.externalRequests)
+	m.registry.MustRegister(o.collectors...)
+
+	return m
+}
+
+func (m *prometheusMetricsImpl) SendExternalServiceMetric(ctx context.Context, start time.Time, serviceName, externalServiceName, reqURL, reqMethod, respStatus string) {
+	labels := prometheus.Labels{
+		LabelService:         serviceName,
+		LabelExternalService: externalServiceName,
+		LabelMethod:          reqMethod,
+		LabelPath:            m.pathTemplate(reqURL),
+		LabelStatus:          respStatus,
+	}
+
+	m.externalDuration.With(labels).Observe(time.Since(start).Seconds())
+	m.externalRequests.With(labels).Inc()
+}
+
+func (m *prometheusMetricsImpl) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *prometheusMetricsImpl) IncCounter(name string, labels Labels) {
+	m.AddCounter(name, 1, labels)
+}
+
+func (m *prometheusMetricsImpl) AddCounter(name string, value float64, labels Labels) {
+	m.mu.Lock()
+	vec, ok := m.counters[name]
+	if !ok {
+		vec = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Name:      name,
+			Help:      fmt.Sprintf("Counter %s.", name),
+		}, labelNames(labels))
+		if !m.register(name, vec) {
+			m.mu.Unlock()
+			return
+		}
+		m.counters[name] = vec
+	}
+	m.mu.Unlock()
+
+	counter, err := vec.GetMetricWith(prometheus.Labels(labels))
+	if err != nil {
+		m.errorHandler(fmt.Errorf("counter %s: %w", name, err))
+		return
+	}
+	if value < 0 {
+		m.errorHandler(fmt.Errorf("counter %s: negative value %v", name, value))
+		return
+	}
+	counter.Add(value)
+}
+
+func (m *prometheusMetricsImpl) SetGauge(name string, value float64, labels Labels) {
+	if gauge, ok := m.gauge(name, labels); ok {
+		gauge.Set(value)
+	}
+}
+
+func (m *prometheusMetricsImpl) AddGauge(name string, value float64, labels Labels) {
+	if gauge, ok := m.gauge(name, labels); ok {
+		gauge.Add(value)
+	}
+}
+
+func (m *prometheusMetricsImpl) gauge(name string, labels Labels) (prometheus.Gauge, bool) {
+	m.mu.Lock()
+	vec, ok := m.gauges[name]
+	if !ok {
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: m.namespace,
+			Name:      name,
+			Help:      fmt.Sprintf("Gauge %s.", name),
+		}, labelNames(labels))
+		if !m.register(name, vec) {
+			m.mu.Unlock()
+			return nil, false
+		}
+		m.gauges[name] = vec
+	}
+	m.mu.Unlock()
+
+	gauge, err := vec.GetMetricWith(prometheus.Labels(labels))
+	if err != nil {
+		m.errorHandler(fmt.Errorf("gauge %s: %w", name, err))
+		return nil, false
+	}
+	return gauge, true
+}
+
+func (m *prometheusMetricsImpl) ObserveHistogram(name string, value float64, labels Labels) {
+	m.mu.Lock()
+	vec, ok := m.histograms[name]
+	if !ok {
+		vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: m.namespace,
+			Name:      name,
+			Help:      fmt.Sprintf("Histogram %s.", name),
+			Buckets:   m.buckets,
+		}, labelNames(labels))
+		if !m.register(name, vec) {
+			m.mu.Unlock()
+			return
+		}
+		m.histograms[name] = vec
+	}
+	m.mu.Unlock()
+
+	histogram, err := vec.GetMetricWith(prometheus.Labels(labels))
+	if err != nil {
+		m.errorHandler(fmt.Errorf("histogram %s: %w", name, err))
+		return
+	}
+	histogram.Observe(value)
+}
+
+func (m *prometheusMetricsImpl) StartTimer(name string, labels Labels) func() {
+	start := time.Now()
+	return func() {
+		m.ObserveHistogram(name, time.Since(start).Seconds(), labels)
+	}
+}
+
+// register registers a new metric, it must be called with the lock held.
+func (m *prometheusMetricsImpl) register(name string, collector prometheus.Collector) bool {
+	if err := m.registry.Register(collector); err != nil {
+		m.errorHandler(fmt.Errorf("register %s: %w", name, err))
+		return false
+	}
+	return true
+}
+
+func labelNames(labels Labels) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
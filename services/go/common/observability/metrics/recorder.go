@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExternalServiceCall is an external service call recorded by a Recorder.
+type ExternalServiceCall struct {
+	ServiceName         string
+	ExternalServiceName string
+	ReqURL              string
+	ReqMethod           string
+	RespStatus          string
+	Duration            time.Duration
+}
+
+// Recorder is a Metrics keeping the recorded values in memory, e.g. for assertions in tests.
+type Recorder struct {
+	mu            sync.Mutex
+	counters      map[string]float64
+	gauges        map[string]float64
+	observations  map[string][]float64
+	externalCalls []ExternalServiceCall
+}
+
+// NewRecorder returns a new empty Recorder.
+func NewRecorder() *Recorder {
+	r := &Recorder{}
+	r.Reset()
+	return r
+}
+
+func (r *Recorder) SendExternalServiceMetric(ctx context.Context, start time.Time, serviceName, externalServiceName, reqURL, reqMethod, respStatus string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.externalCalls = append(r.externalCalls, ExternalServiceCall{
+		ServiceName:         serviceName,
+		ExternalServiceName: externalServiceName,
+		ReqURL:              reqURL,
+		ReqMethod:           reqMethod,
+		RespStatus:          respStatus,
+		Duration:            time.Since(start),
+	})
+}
+
+func (r *Recorder) IncCounter(name string, labels Labels) {
+	r.AddCounter(name, 1, labels)
+}
+
+func (r *Recorder) AddCounter(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters[seriesKey(name, labels)] += value
+}
+
+func (r *Recorder) SetGauge(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges[seriesKey(name, labels)] = value
+}
+
+func (r *Recorder) AddGauge(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges[seriesKey(name, labels)] += value
+}
+
+func (r *Recorder) ObserveHistogram(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, labels)
+	r.observations[key] = append(r.observations[key], value)
+}
+
+func (r *Recorder) StartTimer(name string, labels Labels) func() {
+	start := time.Now()
+	return func() {
+		r.ObserveHistogram(name, time.Since(start).Seconds(), labels)
+	}
+}
+
+// Counter returns the value of a counter with exactly given labels, 0 if it was never recorded.
+func (r *Recorder) Counter(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.counters[seriesKey(name, labels)]
+}
+
+// Gauge returns the value of a gauge with exactly given labels, and whether it was recorded.
+func (r *Recorder) Gauge(name string, labels Labels) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.gauges[seriesKey(name, labels)]
+	return value, ok
+}
+
+// Observations returns the values recorded in a histogram or timer with exactly given labels,
+// in the order they were recorded.
+func (r *Recorder) Observations(name string, labels Labels) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]float64(nil), r.observations[seriesKey(name, labels)]...)
+}
+
+// ExternalServiceCalls returns the recorded external service calls in the order they were recorded.
+func (r *Recorder) ExternalServiceCalls() []ExternalServiceCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]ExternalServiceCall(nil), r.externalCalls...)
+}
+
+// Reset drops all recorded values.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters = map[string]float64{}
+	r.gauges = map[string]float64{}
+	r.observations = map[string][]float64{}
+	r.externalCalls = nil
+}
+
+// seriesKey identifies a metric series, labels are sorted so that their order does not matter.
+func seriesKey(name string, labels Labels) string {
+	names := labelNames(labels)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, label := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(labels[label])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
@@ -0,0 +1,13 @@
+package tracing
+
+// Span attribute keys, following the OpenTelemetry semantic conventions where one exists.
+const (
+	AttributePeerService       = "peer.service"
+	AttributeHTTPMethod        = "http.request.method"
+	AttributeHTTPPath          = "url.path"
+	AttributeHTTPStatusCode    = "http.response.status_code"
+	AttributeRPCSystem         = "rpc.system"
+	AttributeRPCMethod         = "rpc.method"
+	AttributeRPCGRPCStatusCode = "rpc.grpc.status_code" // int, e.g. int(codes.OK)
+	AttributeAttempt           = "attempt"
+)
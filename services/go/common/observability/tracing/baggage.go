@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+// BaggageHeader is the W3C baggage header, see https://www.w3.org/TR/baggage/.
+const BaggageHeader = "baggage"
+
+// Baggage limits, members beyond them are dropped on extraction and rejected by SetBaggage.
+const (
+	MaxBaggageMembers = 64
+	MaxBaggageBytes   = 8192
+)
+
+var (
+	ErrBaggageKeyNotAllowed = errors.New("baggage key not allowed")
+	ErrBaggageTooLarge      = errors.New("baggage too large")
+)
+
+// Baggage keys of the caller identity, propagated from the HealthQuest API down to internal services.
+var (
+	BaggageUserID          = NewStringBaggageKey("user_id")
+	BaggageClientApp       = NewStringBaggageKey("client_app")
+	BaggageQuestCampaignID = NewStringBaggageKey("quest_campaign_id")
+)
+
+var (
+	baggageMu        sync.RWMutex
+	baggageAllowList = map[string]bool{
+		BaggageUserID.Name():          true,
+		BaggageClientApp.Name():       true,
+		BaggageQuestCampaignID.Name(): true,
+	}
+)
+
+// SetBaggageAllowList replaces the names of the baggage members that can be set and propagated.
+func SetBaggageAllowList(names ...string) {
+	allowList := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowList[name] = true
+	}
+
+	baggageMu.Lock()
+	defer baggageMu.Unlock()
+
+	baggageAllowList = allowList
+}
+
+func isBaggageKeyAllowed(name string) bool {
+	baggageMu.RLock()
+	defer baggageMu.RUnlock()
+
+	return baggageAllowList[name]
+}
+
+// BaggageKey is a typed baggage member name.
+type BaggageKey[T any] struct {
+	name   string
+	encode func(T) string
+	decode func(string) (T, error)
+}
+
+// NewStringBaggageKey returns a baggage key with string values.
+func NewStringBaggageKey(name string) BaggageKey[string] {
+	return BaggageKey[string]{
+		name:   name,
+		encode: func(v string) string { return v },
+		decode: func(s string) (string, error) { return s, nil },
+	}
+}
+
+// NewInt64BaggageKey returns a baggage key with int64 values.
+func NewInt64BaggageKey(name string) BaggageKey[int64] {
+	return BaggageKey[int64]{
+		name:   name,
+		encode: func(v int64) string { return strconv.FormatInt(v, 10) },
+		decode: func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) },
+	}
+}
+
+// Name returns the baggage member name.
+func (k BaggageKey[T]) Name() string {
+	return k.name
+}
+
+// Baggage is an immutable set of propagated Key/Value members.
+type Baggage struct {
+	members map[string]string
+}
+
+type baggageContextKey struct{}
+
+// BaggageFromContext returns the baggage associated with a context, empty if there is none.
+func BaggageFromContext(ctx context.Context) Baggage {
+	if b, ok := ctx.Value(baggageContextKey{}).(Baggage); ok {
+		return b
+	}
+	return Baggage{}
+}
+
+// ContextWithBaggage returns a new Context with given baggage.
+func ContextWithBaggage(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, baggageContextKey{}, b)
+}
+
+// SetBaggage returns a new Context whose baggage has the given member set.
+//
+// It fails if the key is not allow-listed or if the baggage would exceed its limits.
+func SetBaggage[T any](ctx context.Context, key BaggageKey[T], value T) (context.Context, error) {
+	if !isBaggageKeyAllowed(key.name) {
+		return ctx, fmt.Errorf("%w: %s", ErrBaggageKeyNotAllowed, key.name)
+	}
+
+	current := BaggageFromContext(ctx)
+	members := make(map[string]string, len(current.members)+1)
+	for k, v := range current.members {
+		members[k] = v
+	}
+	members[key.name] = key.encode(value)
+
+	b := Baggage{members: members}
+	if len(members) > MaxBaggageMembers || len(b.String()) > MaxBaggageBytes {
+		return ctx, fmt.Errorf("%w: setting %s", ErrBaggageTooLarge, key.name)
+	}
+
+	return ContextWithBaggage(ctx, b), nil
+}
+
+// GetBaggage returns the typed value of a baggage member, false if it is missing or malformed.
+func GetBaggage[T any](ctx context.Context, key BaggageKey[T]) (T, bool) {
+	var zero T
+
+	raw, ok := BaggageFromContext(ctx).Get(key.name)
+	if !ok {
+		return zero, false
+	}
+
+	value, err := key.decode(raw)
+	if err != nil {
+		return zero, false
+	}
+
+	return value, true
+}
+
+// Get returns the raw value of a member.
+func (b Baggage) Get(name string) (string, bool) {
+	value, ok := b.members[name]
+	return value, ok
+}
+
+// Len returns the number of members.
+func (b Baggage) Len() int {
+	return len(b.members)
+}
+
+// String encodes the baggage as a baggage header value, members are sorted by name.
+func (b Baggage) String() string {
+	names := make([]string, 0, len(b.members))
+	for name := range b.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+url.PathEscape(b.members[name]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseBaggage decodes baggage header values, keeping only allow-listed members within the limits.
+// Malformed members and member properties are dropped.
+func ParseBaggage(values ...string) Baggage {
+	members := map[string]string{}
+	size := 0
+
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			// Properties after ';' are not supported
+			member, _, _ = strings.Cut(member, ";")
+			name, rawValue, ok := strings.Cut(member, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" || !isBaggageKeyAllowed(name) {
+				continue
+			}
+
+			decoded, err := url.PathUnescape(strings.TrimSpace(rawValue))
+			if err != nil {
+				continue
+			}
+
+			size += len(member) + 1
+			if len(members) >= MaxBaggageMembers || size > MaxBaggageBytes {
+				return Baggage{members: members}
+			}
+			members[name] = decoded
+		}
+	}
+
+	return Baggage{members: members}
+}
+
+// BaggageFromGeneralContext extracts the baggage of an incoming gRPC request.
+func BaggageFromGeneralContext(ctx context.Context) Baggage {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return ParseBaggage(md.Get(BaggageHeader)...)
+	}
+	return Baggage{}
+}
+
+// BaggageFromGinContext extracts the baggage of an incoming HTTP request.
+func BaggageFromGinContext(c *gin.Context) Baggage {
+	return ParseBaggage(c.Request.Header.Values(BaggageHeader)...)
+}
@@ -2,42 +2,92 @@ package tracing
 
 import (
 	"context"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/phuchnd/eeaao/services/go/common/observability/logging"
 	"google.golang.org/grpc/metadata"
 )
 
 // DefaultContextKeyRequestID is an envoy-specific header that is used to consistently sample logs and traces.
 const DefaultContextKeyRequestID = "X-Request-Id"
 
+// Logger keys of the tracing metadata.
+const (
+	LogFieldRequestID = "request_id"
+	LogFieldTraceID   = "trace_id"
+	LogFieldSpanID    = "span_id"
+)
+
 type contextKey struct{}
 
 type RequestTracing struct {
-	// More params like TraceID, SpanID
 	RequestID string
+	// SpanContext is the context of the request in this service, child of the caller span if any.
+	SpanContext SpanContext
+	// ParentSpanID is the caller span ID, zero if the request started the trace.
+	ParentSpanID SpanID
+}
+
+// newRequestTracing builds the tracing metadata of an incoming request from its propagated values.
+//
+// Its span context is the one of the server span, which is only recorded by GinMiddleware and
+// UnaryServerInterceptor. Outside of them, use a span started from the request context instead.
+func newRequestTracing(reqID, traceParent, traceState string) *RequestTracing {
+	if reqID == "" {
+		reqID = uuid.New().String()
+	}
+	meta := &RequestTracing{
+		RequestID: reqID,
+	}
+
+	tracer := DefaultTracer()
+	if parent, err := ParseTraceParent(traceParent); err == nil {
+		parent.TraceState = traceState
+		meta.ParentSpanID = parent.SpanID
+		meta.SpanContext = tracer.newSpanContext(&parent)
+	} else {
+		meta.SpanContext = tracer.newSpanContext(nil)
+	}
+
+	return meta
 }
 
 func NewMetadataFromGeneralContext(ctx context.Context) *RequestTracing {
-	reqID := ""
+	reqID, traceParent, traceState := "", "", ""
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
 		if mdRequestID := md.Get(DefaultContextKeyRequestID); len(mdRequestID) > 0 {
 			reqID = mdRequestID[0]
 		}
+		if mdTraceParent := md.Get(TraceParentHeader); len(mdTraceParent) > 0 {
+			traceParent = mdTraceParent[0]
+		}
+		traceState = strings.Join(md.Get(TraceStateHeader), ",")
 	}
-	if reqID == "" {
-		reqID = uuid.New().String()
-	}
-	return &RequestTracing{
-		RequestID: reqID,
-	}
+	return newRequestTracing(reqID, traceParent, traceState)
 }
 
 // NewContext returns a new Context with given metadata.
+//
+// The context logger is enriched with the tracing fields, so every downstream logging.FromContext
+// call is correlated with the request.
 func NewContext(ctx context.Context, meta *RequestTracing) context.Context {
+	if meta != nil {
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With(meta.LogFields()...))
+	}
 	return context.WithValue(ctx, contextKey{}, meta)
 }
 
+// LogFields returns the tracing metadata as logger Key/Value pairs.
+func (t *RequestTracing) LogFields() []interface{} {
+	fields := []interface{}{LogFieldRequestID, t.RequestID}
+	if t.SpanContext.IsValid() {
+		fields = append(fields, LogFieldTraceID, t.SpanContext.TraceID.String(), LogFieldSpanID, t.SpanContext.SpanID.String())
+	}
+	return fields
+}
+
 // FromContext returns the tracing metadata associated with a context.
 // It returns the nil if no RequestTracing exists.
 func FromContext(ctx context.Context) *RequestTracing {
@@ -49,12 +99,9 @@ func FromContext(ctx context.Context) *RequestTracing {
 
 // FromGinContext returns the tracing metadata associated with a gin context.
 func FromGinContext(c *gin.Context) *RequestTracing {
-	reqID := c.GetHeader(DefaultContextKeyRequestID)
-	// More value from header here
-	if reqID == "" {
-		reqID = uuid.New().String()
-	}
-	return &RequestTracing{
-		RequestID: reqID,
-	}
+	return newRequestTracing(
+		c.GetHeader(DefaultContextKeyRequestID),
+		c.GetHeader(TraceParentHeader),
+		strings.Join(c.Request.Header.Values(TraceStateHeader), ","),
+	)
 }
@@ -0,0 +1,55 @@
+package tracing
+
+import "sync"
+
+// Exporter receives the sampled spans once they end.
+//
+//go:generate mockery --name=Exporter --case=snake --disable-version-string
+type Exporter interface {
+	// ExportSpan exports an ended span, it must not block the caller for long.
+	ExportSpan(span SpanData)
+}
+
+// nopExporter drops all spans.
+type nopExporter struct{}
+
+// NewNopExporter returns an exporter dropping all spans.
+func NewNopExporter() Exporter {
+	return &nopExporter{}
+}
+
+func (e *nopExporter) ExportSpan(SpanData) {}
+
+// InMemoryExporter keeps the exported spans in memory, e.g. for assertions in tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter returns a new empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset drops all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// startServerSpan starts the span of an incoming request, whose context is the one of the request tracing.
+// The returned context carries the request tracing and the span.
+func (t *Tracer) startServerSpan(ctx context.Context, meta *RequestTracing, name string) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:         name,
+			SpanContext:  meta.SpanContext,
+			ParentSpanID: meta.ParentSpanID,
+			StartTime:    time.Now(),
+			Attributes:   map[string]interface{}{},
+		},
+	}
+
+	ctx = NewContext(ctx, meta)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// GinMiddleware returns a gin middleware tracing the incoming requests: the request tracing and the baggage
+// are extracted from the request headers, and the server span is exported once the response is written.
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+
+		ctx := ContextWithBaggage(c.Request.Context(), BaggageFromGinContext(c))
+		ctx, span := DefaultTracer().startServerSpan(ctx, FromGinContext(c), fmt.Sprintf("HTTP %s %s", c.Request.Method, path))
+		span.SetAttribute(AttributeHTTPMethod, c.Request.Method)
+		span.SetAttribute(AttributeHTTPPath, path)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		span.SetAttribute(AttributeHTTPStatusCode, c.Writer.Status())
+		if err := c.Errors.Last(); err != nil {
+			span.RecordError(err)
+		} else if c.Writer.Status() >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("%s", http.StatusText(c.Writer.Status())))
+		}
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor tracing the incoming calls: the request tracing and the
+// baggage are extracted from the incoming metadata, and the server span is exported once the call returns.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = ContextWithBaggage(ctx, BaggageFromGeneralContext(ctx))
+		ctx, span := DefaultTracer().startServerSpan(ctx, NewMetadataFromGeneralContext(ctx), info.FullMethod)
+		span.SetAttribute(AttributeRPCSystem, "grpc")
+		span.SetAttribute(AttributeRPCMethod, info.FullMethod)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		span.SetAttribute(AttributeRPCGRPCStatusCode, int(status.Code(err)))
+		span.RecordError(err)
+		return resp, err
+	}
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+const (
+	traceParentVersion = "00"
+	flagSampled        = 0x01
+)
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceID identifies a trace, it is shared by all spans of a request across services.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the trace ID is not all zeros.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the span ID is not all zeros.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is the part of a span that is propagated to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// TraceState carries vendor-specific data as is.
+	TraceState string
+}
+
+// IsValid reports whether both trace and span IDs are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceParent formats the span context as a traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	flags := byte(0)
+	if sc.Sampled {
+		flags |= flagSampled
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceParent parses a traceparent header value, the trace state is left empty.
+//
+// Versions other than 00 are accepted as long as they start with the version 00 fields.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == traceParentVersion && len(parts) != 4) {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, value)
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return sc, fmt.Errorf("%w: %q", ErrInvalidTraceParent, value)
+	}
+
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return sc, fmt.Errorf("%w: trace-id: %s", ErrInvalidTraceParent, err)
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return sc, fmt.Errorf("%w: parent-id: %s", ErrInvalidTraceParent, err)
+	}
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return sc, fmt.Errorf("%w: trace-flags: %s", ErrInvalidTraceParent, err)
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("%w: all zero ID in %q", ErrInvalidTraceParent, value)
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+
+	return sc, nil
+}
+
+// decodeHex decodes a lowercase hex string of exactly len(dst) bytes.
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("expected %d lowercase hex digits, got %q", hex.EncodedLen(len(dst)), s)
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		binary.BigEndian.PutUint64(t[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(t[8:], rand.Uint64())
+	}
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		binary.BigEndian.PutUint64(s[:], rand.Uint64())
+	}
+	return s
+}
@@ -0,0 +1,242 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+var (
+	tracerMu      sync.RWMutex
+	defaultTracer = NewTracer(NewNopExporter())
+)
+
+// Sampler decides whether a new span is sampled, i.e. recorded and exported.
+type Sampler interface {
+	// ShouldSample decides for a span of given trace, parent is nil for root spans.
+	ShouldSample(traceID TraceID, parent *SpanContext) bool
+}
+
+// SamplerFunc is a function implementing Sampler.
+type SamplerFunc func(traceID TraceID, parent *SpanContext) bool
+
+func (f SamplerFunc) ShouldSample(traceID TraceID, parent *SpanContext) bool {
+	return f(traceID, parent)
+}
+
+// AlwaysSample returns a sampler sampling every span.
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(TraceID, *SpanContext) bool { return true })
+}
+
+// NeverSample returns a sampler sampling no span.
+func NeverSample() Sampler {
+	return SamplerFunc(func(TraceID, *SpanContext) bool { return false })
+}
+
+// TraceIDRatioBased returns a sampler sampling given fraction of traces, consistently for a trace ID.
+func TraceIDRatioBased(fraction float64) Sampler {
+	if fraction >= 1 {
+		return AlwaysSample()
+	}
+	if fraction <= 0 {
+		return NeverSample()
+	}
+
+	threshold := uint64(fraction * (1 << 63))
+	return SamplerFunc(func(traceID TraceID, _ *SpanContext) bool {
+		return binary.BigEndian.Uint64(traceID[8:])>>1 < threshold
+	})
+}
+
+// ParentBased returns a sampler following the parent decision, root spans are sampled by given sampler.
+func ParentBased(root Sampler) Sampler {
+	return SamplerFunc(func(traceID TraceID, parent *SpanContext) bool {
+		if parent != nil {
+			return parent.Sampled
+		}
+		return root.ShouldSample(traceID, nil)
+	})
+}
+
+// TracerOpt is an option on a given Tracer.
+type TracerOpt func(t *Tracer)
+
+// Tracer creates spans and exports the sampled ones.
+type Tracer struct {
+	exporter Exporter
+	sampler  Sampler
+}
+
+// NewTracer creates a new Tracer exporting to given exporter, sampling every trace by default.
+func NewTracer(exporter Exporter, opts ...TracerOpt) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		sampler:  ParentBased(AlwaysSample()),
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+// WithSampler returns an option that allows setting of the tracer sampler.
+func WithSampler(sampler Sampler) TracerOpt {
+	return func(t *Tracer) {
+		t.sampler = sampler
+	}
+}
+
+// SetDefaultTracer sets the tracer used by the package-level functions.
+func SetDefaultTracer(tracer *Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+
+	defaultTracer = tracer
+}
+
+// DefaultTracer returns the tracer used by the package-level functions.
+func DefaultTracer() *Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+
+	return defaultTracer
+}
+
+// newSpanContext creates the context of a new span, child of parent if given.
+func (t *Tracer) newSpanContext(parent *SpanContext) SpanContext {
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent != nil {
+		sc.TraceID = parent.TraceID
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	sc.Sampled = t.sampler.ShouldSample(sc.TraceID, parent)
+
+	return sc
+}
+
+// Start starts a new span, child of the span or request tracing found in ctx, if any.
+// The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			StartTime:  time.Now(),
+			Attributes: map[string]interface{}{},
+		},
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.data.ParentSpanID = parent.SpanID
+		span.data.SpanContext = t.newSpanContext(&parent)
+	} else {
+		span.data.SpanContext = t.newSpanContext(nil)
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// StartSpan starts a new span with the default tracer, see Tracer.Start.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	return DefaultTracer().Start(ctx, name)
+}
+
+type spanContextKey struct{}
+
+// SpanFromContext returns the active span of a context, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		return span
+	}
+	return nil
+}
+
+// SpanContextFromContext returns the context of the active span, or of the request tracing if there is
+// no active span.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext(), true
+	}
+	if meta := FromContext(ctx); meta != nil && meta.SpanContext.IsValid() {
+		return meta.SpanContext, true
+	}
+	return SpanContext{}, false
+}
+
+// SpanData is a snapshot of a span, as handed to exporters.
+type SpanData struct {
+	Name         string
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Err          error
+}
+
+// Duration returns the span duration.
+func (d SpanData) Duration() time.Duration {
+	return d.EndTime.Sub(d.StartTime)
+}
+
+// Span is a timed operation within a trace.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span context to propagate.
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetAttribute sets a Key/Value attribute on the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Attributes[key] = value
+}
+
+// RecordError marks the span as failed with given error, nil errors are ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Err = err
+}
+
+// End ends the span and exports it if it is sampled. Subsequent calls are no-ops.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.exporter.ExportSpan(data)
+	}
+}
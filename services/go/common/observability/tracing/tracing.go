@@ -7,18 +7,52 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// PropagateRequestIDToContext appends the request ID and the W3C trace context of the active span,
+// or of the request, to the outgoing gRPC metadata.
 func PropagateRequestIDToContext(ctx context.Context) context.Context {
-	requestMetadata := FromContext(ctx)
-	if requestMetadata == nil {
+	var kv []string
+	if requestMetadata := FromContext(ctx); requestMetadata != nil {
+		kv = append(kv, DefaultContextKeyRequestID, requestMetadata.RequestID)
+	}
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		kv = append(kv, TraceParentHeader, sc.TraceParent())
+		if sc.TraceState != "" {
+			kv = append(kv, TraceStateHeader, sc.TraceState)
+		}
+	}
+	if len(kv) == 0 {
 		return ctx
 	}
-	return metadata.AppendToOutgoingContext(ctx, DefaultContextKeyRequestID, requestMetadata.RequestID)
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// PropagateBaggageToContext appends the baggage to the outgoing gRPC metadata. The baggage carries the
+// caller identity, only propagate it to trusted services.
+func PropagateBaggageToContext(ctx context.Context) context.Context {
+	if baggage := BaggageFromContext(ctx); baggage.Len() > 0 {
+		return metadata.AppendToOutgoingContext(ctx, BaggageHeader, baggage.String())
+	}
+	return ctx
 }
 
+// PropagateRequestIDToHeader sets the request ID and the W3C trace context of the active span,
+// or of the request, to the outgoing HTTP header.
 func PropagateRequestIDToHeader(ctx context.Context, outGoingHeader *http.Header) {
-	requestMetadata := FromContext(ctx)
-	if requestMetadata == nil {
-		return
+	if requestMetadata := FromContext(ctx); requestMetadata != nil {
+		outGoingHeader.Set(DefaultContextKeyRequestID, requestMetadata.RequestID)
+	}
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		outGoingHeader.Set(TraceParentHeader, sc.TraceParent())
+		if sc.TraceState != "" {
+			outGoingHeader.Set(TraceStateHeader, sc.TraceState)
+		}
+	}
+}
+
+// PropagateBaggageToHeader sets the baggage to the outgoing HTTP header. The baggage carries the caller
+// identity, only propagate it to trusted services.
+func PropagateBaggageToHeader(ctx context.Context, outGoingHeader *http.Header) {
+	if baggage := BaggageFromContext(ctx); baggage.Len() > 0 {
+		outGoingHeader.Set(BaggageHeader, baggage.String())
 	}
-	outGoingHeader.Set(DefaultContextKeyRequestID, requestMetadata.RequestID)
 }